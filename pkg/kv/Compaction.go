@@ -0,0 +1,275 @@
+package kv
+
+import (
+	"bytes"
+	"sort"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/sstable"
+)
+
+// compaction represents the SSTables that are merged by a single compaction of the level into the next level.
+// tables are the SSTables of the level (the newest first), nextLevelTables are the SSTables of the next level that
+// overlap the key range of tables, and lowerLevelTables are all the SSTables of the levels below the next level.
+type compaction struct {
+	level            int
+	tables           []*sstable.TableReader
+	nextLevelTables  []*sstable.TableReader
+	lowerLevelTables []*sstable.TableReader
+}
+
+// versionedEntry is a key/value pair read from an SSTable during compaction.
+type versionedEntry struct {
+	key   mvcc.VersionedKey
+	value mvcc.Value
+}
+
+// compact runs a single compaction and returns true if a compaction was run.
+// A compaction merges the SSTables of a level that is over its budget with the overlapping SSTables of the next level,
+// writes the merged key/value pairs to new SSTables in the next level and removes the merged SSTables.
+// While merging, compact drops:
+// 1. all the versions of a key that are older than the latest version which is less than or equal to the compaction
+// watermark, no transaction can read them anymore.
+// 2. the deletion tombstone which is the latest version less than or equal to the watermark, if no level below the next
+// level has the key. There is no older version left that the tombstone hides.
+// compact is ONLY called from the Compactor, which makes it the only writer of the levels other than level 0.
+func (workspace *Workspace) compact() (bool, error) {
+	compaction := workspace.pickCompaction()
+	if compaction == nil {
+		return false, nil
+	}
+	tables, err := workspace.merge(compaction)
+	if err != nil {
+		return false, err
+	}
+	if err := workspace.install(compaction, tables); err != nil {
+		workspace.removeTables(tables)
+		return false, err
+	}
+	workspace.removeTables(append(compaction.tables, compaction.nextLevelTables...))
+	return true, nil
+}
+
+// pickCompaction picks the first level (starting from level 0) that is over its budget.
+// Level 0 is over its budget if it has more than Level0MaxTables SSTables, all of which are compacted together because
+// their key ranges overlap.
+// Level N (N >= 1) is over its budget if its size exceeds Level1MaxSizeInBytes * LevelSizeMultiplier^(N-1), and its
+// oldest SSTable is compacted. The last level has no budget.
+func (workspace *Workspace) pickCompaction() *compaction {
+	workspace.lock.RLock()
+	defer workspace.lock.RUnlock()
+
+	for level := 0; level < len(workspace.levels)-1; level++ {
+		var tables []*sstable.TableReader
+		if level == 0 {
+			if len(workspace.levels[0]) <= workspace.options.Level0MaxTables {
+				continue
+			}
+			for index := len(workspace.levels[0]) - 1; index >= 0; index-- {
+				tables = append(tables, workspace.levels[0][index])
+			}
+		} else {
+			if workspace.levelSizeInBytes(level) <= workspace.maxLevelSizeInBytes(level) {
+				continue
+			}
+			tables = append(tables, workspace.oldestTableIn(level))
+		}
+
+		compaction := &compaction{level: level, tables: tables}
+		minKey, maxKey := keyRangeOf(tables)
+		for _, table := range workspace.levels[level+1] {
+			if table.Overlaps(minKey, maxKey) {
+				compaction.nextLevelTables = append(compaction.nextLevelTables, table)
+			}
+		}
+		for _, lowerLevelTables := range workspace.levels[level+2:] {
+			compaction.lowerLevelTables = append(compaction.lowerLevelTables, lowerLevelTables...)
+		}
+		return compaction
+	}
+	return nil
+}
+
+// merge merges the SSTables of the compaction and writes the retained key/value pairs to new SSTables.
+// A new SSTable is started once the current one reaches SSTableSizeInBytes. All the versions of a key are written to
+// the same SSTable, so the SSTables of a level never overlap.
+func (workspace *Workspace) merge(compaction *compaction) ([]*sstable.TableReader, error) {
+	var iterators []*sstable.TableIterator
+	for _, table := range append(compaction.tables, compaction.nextLevelTables...) {
+		iterators = append(iterators, table.NewIterator())
+	}
+	iterator := sstable.NewMergeIterator(iterators)
+	watermark := workspace.watermark()
+
+	var tables []*sstable.TableReader
+	builder := sstable.NewSSTableBuilder(workspace.options)
+	for iterator.IsValid() {
+		key := iterator.Key().KeySlice()
+
+		var versions []versionedEntry
+		for ; iterator.IsValid() && bytes.Equal(iterator.Key().KeySlice(), key); iterator.Next() {
+			versions = append(versions, versionedEntry{key: iterator.Key(), value: iterator.Value()})
+		}
+		for _, entry := range compaction.retain(versions, watermark) {
+			builder.Add(entry.key, entry.value)
+		}
+		if builder.SizeInBytes() >= workspace.options.SSTableSizeInBytes {
+			table, err := workspace.buildTable(builder, compaction.level+1)
+			if err != nil {
+				workspace.removeTables(tables)
+				return nil, err
+			}
+			tables = append(tables, table)
+			builder = sstable.NewSSTableBuilder(workspace.options)
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		workspace.removeTables(tables)
+		return nil, err
+	}
+	if !builder.IsEmpty() {
+		table, err := workspace.buildTable(builder, compaction.level+1)
+		if err != nil {
+			workspace.removeTables(tables)
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// install replaces the SSTables of the compaction with the new SSTables in the levels.
+// The Manifest is written before the levels are replaced under the lock.
+func (workspace *Workspace) install(compaction *compaction, tables []*sstable.TableReader) error {
+	workspace.lock.Lock()
+	defer workspace.lock.Unlock()
+
+	levels := workspace.copyOfLevels()
+	levels[compaction.level] = withoutTables(levels[compaction.level], compaction.tables)
+
+	nextLevel := append(withoutTables(levels[compaction.level+1], compaction.nextLevelTables), tables...)
+	sort.Slice(nextLevel, func(i, j int) bool {
+		return bytes.Compare(nextLevel[i].MinKey(), nextLevel[j].MinKey()) < 0
+	})
+	levels[compaction.level+1] = nextLevel
+
+	if err := newManifest(levels).write(workspace.options.DbDirectory); err != nil {
+		return err
+	}
+	workspace.levels = levels
+	return nil
+}
+
+// retain returns the versions of a key (in the increasing order of versions) that are retained by the compaction.
+func (compaction *compaction) retain(versions []versionedEntry, watermark uint64) []versionedEntry {
+	latestVersionBelowWatermark := -1
+	for index, entry := range versions {
+		if entry.key.Version <= watermark {
+			latestVersionBelowWatermark = index
+		}
+	}
+	if latestVersionBelowWatermark == -1 {
+		return versions
+	}
+	retained := versions[latestVersionBelowWatermark:]
+	if retained[0].value.IsDeleted() && !compaction.hasKeyInLowerLevels(retained[0].key.KeySlice()) {
+		return retained[1:]
+	}
+	return retained
+}
+
+// hasKeyInLowerLevels returns true if any SSTable below the next level may contain the key.
+func (compaction *compaction) hasKeyInLowerLevels(key []byte) bool {
+	for _, table := range compaction.lowerLevelTables {
+		if table.ContainsKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTable builds the SSTable with a new file id and opens it for the level it is placed in.
+func (workspace *Workspace) buildTable(builder *sstable.TableBuilder, level int) (*sstable.TableReader, error) {
+	fileId := workspace.lastFileId.Add(1)
+	if err := builder.Build(fileId); err != nil {
+		return nil, err
+	}
+	return workspace.openTable(fileId, level)
+}
+
+// removeTables releases the reference of the Workspace on the SSTables, and removes their files once they are not read anymore.
+func (workspace *Workspace) removeTables(tables []*sstable.TableReader) {
+	for _, table := range tables {
+		table.RemoveOnRelease()
+		if err := table.DecrementReference(); err != nil {
+			//TODO: Removes println in favor of logging
+			println("error while removing sstable ", table.FileId(), err.Error())
+		}
+	}
+}
+
+// watermark returns the compaction watermark, or 0 if no watermark is set.
+func (workspace *Workspace) watermark() uint64 {
+	watermark := workspace.compactionWatermark.Load()
+	if watermark == nil {
+		return 0
+	}
+	return (*watermark)()
+}
+
+// levelSizeInBytes returns the total size of all the SSTables in the level. It is called with the lock held.
+func (workspace *Workspace) levelSizeInBytes(level int) uint64 {
+	size := uint64(0)
+	for _, table := range workspace.levels[level] {
+		size = size + table.SizeInBytes()
+	}
+	return size
+}
+
+// maxLevelSizeInBytes returns the budget of the level (N >= 1): Level1MaxSizeInBytes * LevelSizeMultiplier^(N-1).
+func (workspace *Workspace) maxLevelSizeInBytes(level int) uint64 {
+	maxSize := workspace.options.Level1MaxSizeInBytes
+	for index := 1; index < level; index++ {
+		maxSize = maxSize * workspace.options.LevelSizeMultiplier
+	}
+	return maxSize
+}
+
+// oldestTableIn returns the SSTable with the smallest file id in the level. It is called with the lock held.
+func (workspace *Workspace) oldestTableIn(level int) *sstable.TableReader {
+	oldest := workspace.levels[level][0]
+	for _, table := range workspace.levels[level] {
+		if table.FileId() < oldest.FileId() {
+			oldest = table
+		}
+	}
+	return oldest
+}
+
+// keyRangeOf returns the smallest and the largest key of all the SSTables.
+func keyRangeOf(tables []*sstable.TableReader) ([]byte, []byte) {
+	minKey, maxKey := tables[0].MinKey(), tables[0].MaxKey()
+	for _, table := range tables[1:] {
+		if bytes.Compare(table.MinKey(), minKey) < 0 {
+			minKey = table.MinKey()
+		}
+		if bytes.Compare(table.MaxKey(), maxKey) > 0 {
+			maxKey = table.MaxKey()
+		}
+	}
+	return minKey, maxKey
+}
+
+// withoutTables returns the SSTables excluding the ones to be removed.
+func withoutTables(tables []*sstable.TableReader, removed []*sstable.TableReader) []*sstable.TableReader {
+	isRemoved := make(map[*sstable.TableReader]bool)
+	for _, table := range removed {
+		isRemoved[table] = true
+	}
+	var remaining []*sstable.TableReader
+	for _, table := range tables {
+		if !isRemoved[table] {
+			remaining = append(remaining, table)
+		}
+	}
+	return remaining
+}
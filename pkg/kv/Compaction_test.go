@@ -0,0 +1,273 @@
+package kv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable"
+)
+
+func addTable(t *testing.T, workspace *Workspace, level int, entries ...versionedEntry) *sstable.TableReader {
+	builder := sstable.NewSSTableBuilder(workspace.options)
+	for _, entry := range entries {
+		builder.Add(entry.key, entry.value)
+	}
+	table, err := workspace.buildTable(builder, level)
+	assert.Nil(t, err)
+
+	workspace.levels[level] = append(workspace.levels[level], table)
+	return table
+}
+
+func entry(key string, version uint64, value string) versionedEntry {
+	return versionedEntry{key: mvcc.NewVersionedKey([]byte(key), version), value: mvcc.NewValue([]byte(value))}
+}
+
+func deletedEntry(key string, version uint64) versionedEntry {
+	return versionedEntry{key: mvcc.NewVersionedKey([]byte(key), version), value: mvcc.NewDeletedValue()}
+}
+
+func totalTablesIn(workspace *Workspace, level int) int {
+	workspace.lock.RLock()
+	defer workspace.lock.RUnlock()
+	return len(workspace.levels[level])
+}
+
+func TestDoesNotCompactGivenAllTheLevelsAreWithinTheirBudget(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(2))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"))
+	addTable(t, workspace, 0, entry("SSD", 2, "Solid state"))
+
+	compacted, err := workspace.compact()
+	assert.Nil(t, err)
+	assert.False(t, compacted)
+}
+
+func TestCompactsLevel0IntoLevel1(t *testing.T) {
+	directory := t.TempDir()
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetLevel0MaxTables(1))
+	defer workspace.Stop()
+
+	older := addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"), entry("SSD", 1, "Solid state"))
+	newer := addTable(t, workspace, 0, entry("HDD", 2, "Hard disk drive"), entry("NVMe", 2, "Non-volatile memory"))
+
+	compacted, err := workspace.compact()
+	assert.Nil(t, err)
+	assert.True(t, compacted)
+
+	assert.Equal(t, 0, totalTablesIn(workspace, 0))
+	assert.Equal(t, 1, totalTablesIn(workspace, 1))
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("NVMe"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Non-volatile memory", string(valueWithVersion.ValueSlice()))
+
+	for _, table := range []*sstable.TableReader{older, newer} {
+		_, err := os.Stat(sstable.TableFilePath(table.FileId(), directory))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestCompactsLevel0WithTheOverlappingTablesOfLevel1(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 1, entry("A", 1, "a"))
+	addTable(t, workspace, 1, entry("HDD", 1, "Hard disk"), entry("SSD", 1, "Solid state"))
+	addTable(t, workspace, 0, entry("HDD", 2, "Hard disk drive"))
+
+	compacted, err := workspace.compact()
+	assert.Nil(t, err)
+	assert.True(t, compacted)
+
+	assert.Equal(t, 0, totalTablesIn(workspace, 0))
+	assert.Equal(t, 2, totalTablesIn(workspace, 1))
+	assert.Equal(t, "A", string(workspace.levels[1][0].MinKey()))
+	assert.Equal(t, "HDD", string(workspace.levels[1][1].MinKey()))
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Solid state", string(valueWithVersion.ValueSlice()))
+}
+
+func TestCompactsALevelThatIsOverItsSizeBudget(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel1MaxSizeInBytes(1))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 1, entry("HDD", 1, "Hard disk"))
+
+	compacted, err := workspace.compact()
+	assert.Nil(t, err)
+	assert.True(t, compacted)
+
+	assert.Equal(t, 0, totalTablesIn(workspace, 1))
+	assert.Equal(t, 1, totalTablesIn(workspace, 2))
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestSplitsTheCompactedTablesBySize(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0).SetSSTableSizeInBytes(1))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"), entry("HDD", 2, "Hard disk drive"), entry("SSD", 1, "Solid state"))
+
+	compacted, err := workspace.compact()
+	assert.Nil(t, err)
+	assert.True(t, compacted)
+
+	assert.Equal(t, 2, totalTablesIn(workspace, 1))
+	assert.Equal(t, "HDD", string(workspace.levels[1][0].MaxKey()))
+	assert.Equal(t, "SSD", string(workspace.levels[1][1].MinKey()))
+}
+
+func TestCompactionDropsVersionsOlderThanTheWatermark(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0))
+	defer workspace.Stop()
+	workspace.SetCompactionWatermark(func() uint64 {
+		return 3
+	})
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"), entry("HDD", 2, "Hard disk drive"), entry("HDD", 4, "HDD"))
+
+	compacted, err := workspace.compact()
+	assert.Nil(t, err)
+	assert.True(t, compacted)
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, false, ok)
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 4))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "HDD", string(valueWithVersion.ValueSlice()))
+}
+
+func TestCompactionKeepsAllTheVersionsWithoutAWatermark(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"), entry("HDD", 2, "Hard disk drive"))
+
+	_, _ = workspace.compact()
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestCompactionRemovesTheTombstoneGivenNoOlderVersionIsLeftBelowIt(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0))
+	defer workspace.Stop()
+	workspace.SetCompactionWatermark(func() uint64 {
+		return 5
+	})
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"), deletedEntry("HDD", 2), entry("SSD", 1, "Solid state"))
+
+	_, _ = workspace.compact()
+
+	assert.Equal(t, 1, totalTablesIn(workspace, 1))
+	assert.Equal(t, "SSD", string(workspace.levels[1][0].MinKey()))
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 5))
+	assert.Equal(t, false, ok)
+}
+
+func TestCompactionKeepsTheTombstoneGivenAnOlderVersionIsInALowerLevel(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0))
+	defer workspace.Stop()
+	workspace.SetCompactionWatermark(func() uint64 {
+		return 5
+	})
+
+	addTable(t, workspace, 2, entry("HDD", 1, "Hard disk"))
+	addTable(t, workspace, 0, deletedEntry("HDD", 2))
+
+	_, _ = workspace.compact()
+
+	assert.Equal(t, 1, totalTablesIn(workspace, 1))
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 5))
+	assert.Equal(t, false, ok)
+}
+
+func TestCompactsTheFlushedMemtables(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20).SetLevel0MaxTables(1)
+	workspace, _ := NewWorkspace(options)
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("NVMe"), 3), mvcc.NewValue([]byte("Non-volatile memory")))
+
+	assert.Eventually(t, func() bool {
+		return totalTablesIn(workspace, 0) == 0 && totalTablesIn(workspace, 1) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	for key, value := range map[string]string{"HDD": "Hard disk drive", "SSD": "Solid state drive"} {
+		valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte(key), 3))
+		assert.Equal(t, true, ok)
+		assert.Equal(t, value, string(valueWithVersion.ValueSlice()))
+	}
+}
+
+func TestRecoversTheLevelsFromTheManifest(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetLevel0MaxTables(0)
+	workspace, _ := NewWorkspace(options)
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"))
+	_, _ = workspace.compact()
+	workspace.Stop()
+
+	recovered, err := Open(options.SetLevel0MaxTables(4))
+	assert.Nil(t, err)
+	defer recovered.Stop()
+
+	assert.Equal(t, 0, totalTablesIn(recovered, 0))
+	assert.Equal(t, 1, totalTablesIn(recovered, 1))
+
+	valueWithVersion, ok, _ := recovered.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestRemovesTheTablesThatAreNotInTheManifestOnRecovery(t *testing.T) {
+	directory := t.TempDir()
+	options := option.DefaultOptions().SetDbDirectory(directory)
+	workspace, _ := NewWorkspace(options)
+	addTable(t, workspace, 1, entry("HDD", 1, "Hard disk"))
+	assert.Nil(t, newManifest(workspace.levels).write(directory))
+
+	orphan := addTable(t, workspace, 1, entry("SSD", 1, "Solid state"))
+	workspace.Stop()
+
+	recovered, err := Open(options)
+	assert.Nil(t, err)
+	defer recovered.Stop()
+
+	assert.Equal(t, 1, totalTablesIn(recovered, 1))
+	_, err = os.Stat(sstable.TableFilePath(orphan.FileId(), directory))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,69 @@
+package kv
+
+import "sync"
+
+// Compactor compacts the SSTables across levels in the background.
+// It is a single goroutine that is triggered after every flush of a memtable. Once triggered, it keeps compacting
+// till every level is within its budget (refer to Workspace.compact).
+// A compaction that fails leaves the levels unchanged, and is attempted again on the next trigger.
+type Compactor struct {
+	triggerChannel chan struct{}
+	stopChannel    chan struct{}
+	stoppedChannel chan struct{}
+	stopOnce       sync.Once
+	workspace      *Workspace
+}
+
+// NewCompactor creates a new instance of Compactor. It is called once in the entire application.
+func NewCompactor(workspace *Workspace) *Compactor {
+	compactor := &Compactor{
+		triggerChannel: make(chan struct{}, 1),
+		stopChannel:    make(chan struct{}),
+		stoppedChannel: make(chan struct{}),
+		workspace:      workspace,
+	}
+	go compactor.spin()
+	return compactor
+}
+
+// Trigger triggers a compaction. It does not block, multiple triggers that arrive while a compaction is running are
+// collapsed into one.
+func (compactor *Compactor) Trigger() {
+	select {
+	case compactor.triggerChannel <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the Compactor, and returns after the compaction in progress (if any) is done.
+// Stop can be called more than once, only the first call stops the Compactor.
+func (compactor *Compactor) Stop() {
+	compactor.stopOnce.Do(func() {
+		close(compactor.stopChannel)
+	})
+	<-compactor.stoppedChannel
+}
+
+// spin is invoked as a single goroutine [`go spin()`] and it reads either an event from `stopChannel` or a trigger
+// from the `triggerChannel`.
+func (compactor *Compactor) spin() {
+	defer close(compactor.stoppedChannel)
+	for {
+		select {
+		case <-compactor.triggerChannel:
+			for {
+				compacted, err := compactor.workspace.compact()
+				if err != nil {
+					//TODO: Removes println in favor of logging
+					println("error while compacting sstables ", err.Error())
+					break
+				}
+				if !compacted {
+					break
+				}
+			}
+		case <-compactor.stopChannel:
+			return
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package kv
+
+import (
+	"bytes"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/sstable"
+)
+
+// entryIterator is an iterator over the key/value pairs of a single memtable (mvcc.Iterator) or a single
+// SSTable (sstable.TableIterator), in the increasing order of mvcc.VersionedKey.
+type entryIterator interface {
+	Seek(key mvcc.VersionedKey)
+	IsValid() bool
+	Key() mvcc.VersionedKey
+	Value() mvcc.Value
+	Next()
+}
+
+// Iterator is a merged iterator over all the memtables and all the SSTables of the Workspace.
+// Iterator returns the keys in the range [start, end) in the increasing order. For each key, it returns the latest version
+// that is less than or equal to the version of the Iterator, and it skips the key if that version is deleted.
+// The value pointers are resolved to the values from the value log, and the Iterator stops at the first value that can
+// not be read (refer to Err).
+// Iterator holds a reference on all the SSTables, it is ESSENTIAL to call Close once the Iterator is not needed.
+type Iterator struct {
+	workspace *Workspace
+	iterators []entryIterator
+	tables    []*sstable.TableReader
+	end       []byte
+	version   uint64
+	key       []byte
+	value     mvcc.ValueWithVersion
+	valid     bool
+	err       error
+}
+
+// newIterator creates a new instance of Iterator and positions it at the first visible key that is >= start.
+// A nil end means that the range is not bounded on the right.
+func newIterator(workspace *Workspace, start []byte, end []byte, version uint64) *Iterator {
+	memtables, tables := workspace.allMemtablesAndTables()
+
+	iterator := &Iterator{workspace: workspace, tables: tables, end: end, version: version}
+	for _, memtable := range memtables {
+		iterator.iterators = append(iterator.iterators, memtable.NewIterator())
+	}
+	for _, table := range tables {
+		iterator.iterators = append(iterator.iterators, table.NewIterator())
+	}
+	for _, entryIterator := range iterator.iterators {
+		entryIterator.Seek(mvcc.NewVersionedKey(start, 0))
+	}
+	iterator.moveToNextVisibleKey()
+	return iterator
+}
+
+// IsValid returns true if the Iterator is positioned at a key, false otherwise.
+func (iterator *Iterator) IsValid() bool {
+	return iterator.valid
+}
+
+// Key returns the key (without the version) the Iterator is positioned at.
+func (iterator *Iterator) Key() []byte {
+	return iterator.key
+}
+
+// Value returns the latest visible value (with its version) of the key the Iterator is positioned at.
+func (iterator *Iterator) Value() mvcc.ValueWithVersion {
+	return iterator.value
+}
+
+// Next moves the Iterator to the next visible key. It is ESSENTIAL to call IsValid() before calling Next.
+func (iterator *Iterator) Next() {
+	iterator.moveToNextVisibleKey()
+}
+
+// Err returns the first error encountered while reading the SSTables or the value log.
+func (iterator *Iterator) Err() error {
+	if iterator.err != nil {
+		return iterator.err
+	}
+	for _, entryIterator := range iterator.iterators {
+		if tableIterator, ok := entryIterator.(*sstable.TableIterator); ok && tableIterator.Err() != nil {
+			return tableIterator.Err()
+		}
+	}
+	return nil
+}
+
+// Close releases the references on the SSTables.
+func (iterator *Iterator) Close() {
+	iterator.workspace.releaseTables(iterator.tables)
+	iterator.tables = nil
+	iterator.valid = false
+}
+
+// moveToNextVisibleKey consumes all the versions of the next key (across all the iterators), and positions the Iterator
+// at that key if its latest version <= the version of the Iterator exists and is not deleted. Otherwise, it moves
+// on to the key after.
+func (iterator *Iterator) moveToNextVisibleKey() {
+	iterator.valid = false
+	for {
+		smallest := iterator.smallest()
+		if smallest == nil {
+			return
+		}
+		key := smallest.Key().KeySlice()
+		if iterator.end != nil && bytes.Compare(key, iterator.end) >= 0 {
+			return
+		}
+
+		var visible *mvcc.ValueWithVersion
+		for _, entryIterator := range iterator.iterators {
+			for ; entryIterator.IsValid() && bytes.Equal(entryIterator.Key().KeySlice(), key); entryIterator.Next() {
+				version := entryIterator.Key().Version
+				if version <= iterator.version && (visible == nil || version > visible.Version) {
+					value := mvcc.NewValueWithVersion(entryIterator.Value(), version)
+					visible = &value
+				}
+			}
+		}
+		if visible != nil && !visible.IsDeleted() {
+			value, err := iterator.workspace.resolveValue(*visible)
+			if err != nil {
+				iterator.err = err
+				return
+			}
+			iterator.key, iterator.value, iterator.valid = key, value, true
+			return
+		}
+	}
+}
+
+// smallest returns the iterator positioned at the smallest key, or nil if all the iterators are exhausted.
+func (iterator *Iterator) smallest() entryIterator {
+	var smallest entryIterator
+	for _, entryIterator := range iterator.iterators {
+		if !entryIterator.IsValid() {
+			continue
+		}
+		if smallest == nil || entryIterator.Key().Compare(smallest.Key()) < 0 {
+			smallest = entryIterator
+		}
+	}
+	return smallest
+}
@@ -0,0 +1,98 @@
+package kv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable"
+)
+
+func allKeyValuesOf(iterator *Iterator) []string {
+	var keyValues []string
+	for ; iterator.IsValid(); iterator.Next() {
+		keyValues = append(keyValues, string(iterator.Key())+"="+string(iterator.Value().ValueSlice()))
+	}
+	return keyValues
+}
+
+func TestScanMergesTheMemtableAndTheSSTables(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 1, entry("HDD", 1, "Hard disk"), entry("SSD", 1, "Solid state"))
+	addTable(t, workspace, 0, entry("NVMe", 2, "Non volatile memory"))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("Disk"), 3), mvcc.NewValue([]byte("Storage")))
+
+	iterator := workspace.Scan(nil, nil, 3)
+	defer iterator.Close()
+
+	assert.Equal(t, []string{"Disk=Storage", "HDD=Hard disk", "NVMe=Non volatile memory", "SSD=Solid state"}, allKeyValuesOf(iterator))
+	assert.Nil(t, iterator.Err())
+}
+
+func TestScanReturnsTheLatestVisibleVersionOfAKey(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 4), mvcc.NewValue([]byte("Hard disk drive (new)")))
+
+	iterator := workspace.Scan(nil, nil, 3)
+	defer iterator.Close()
+
+	assert.Equal(t, true, iterator.IsValid())
+	assert.Equal(t, uint64(2), iterator.Value().Version)
+	assert.Equal(t, []string{"HDD=Hard disk drive"}, allKeyValuesOf(iterator))
+}
+
+func TestScanSkipsTheDeletedKeys(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"), entry("SSD", 1, "Solid state"))
+	_ = workspace.Delete(mvcc.NewVersionedKey([]byte("HDD"), 2))
+
+	iterator := workspace.Scan(nil, nil, 2)
+	defer iterator.Close()
+	assert.Equal(t, []string{"SSD=Solid state"}, allKeyValuesOf(iterator))
+
+	olderIterator := workspace.Scan(nil, nil, 1)
+	defer olderIterator.Close()
+	assert.Equal(t, []string{"HDD=Hard disk", "SSD=Solid state"}, allKeyValuesOf(olderIterator))
+}
+
+func TestScanReturnsTheKeysInTheRange(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 1, entry("A", 1, "a"), entry("B", 1, "b"), entry("C", 1, "c"))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("BB"), 1), mvcc.NewValue([]byte("bb")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("D"), 1), mvcc.NewValue([]byte("d")))
+
+	iterator := workspace.Scan([]byte("B"), []byte("D"), 1)
+	defer iterator.Close()
+
+	assert.Equal(t, []string{"B=b", "BB=bb", "C=c"}, allKeyValuesOf(iterator))
+}
+
+func TestScanHoldsTheReferencesOfTheSSTablesTillClose(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	table := addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"))
+
+	iterator := workspace.Scan(nil, nil, 1)
+	workspace.levels[0] = nil
+	workspace.removeTables([]*sstable.TableReader{table})
+
+	assert.Equal(t, []string{"HDD=Hard disk"}, allKeyValuesOf(iterator))
+	_, err := os.Stat(sstable.TableFilePath(table.FileId(), workspace.options.DbDirectory))
+	assert.Nil(t, err)
+
+	iterator.Close()
+	_, err = os.Stat(sstable.TableFilePath(table.FileId(), workspace.options.DbDirectory))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,165 @@
+package kv
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"tinydb/pkg/kv/errors"
+	"tinydb/pkg/kv/sstable"
+	"unsafe"
+)
+
+const (
+	manifestFileName          = "MANIFEST"
+	temporaryManifestFileName = "MANIFEST.tmp"
+)
+
+const manifestUint32Size = int(unsafe.Sizeof(uint32(0)))
+const manifestUint64Size = int(unsafe.Sizeof(uint64(0)))
+
+// Manifest records the file ids of the SSTables that are present in each level.
+// The Manifest is rewritten (completely) every time the SSTables change, which happens when a memtable is flushed and
+// when a compaction finishes. It is written to a temporary file which is then renamed, so that the Manifest is
+// replaced atomically. The directory is synced after the rename, so that the rename is durable.
+// The order of the SSTables within a level is preserved: level 0 keeps the oldest SSTable first, and the other levels
+// keep the SSTables in the increasing order of their keys.
+/*
+Structure of the Manifest.
++----------------------+-------------------+------------------+-----+
+| 4 bytes total levels | 4 bytes level0    | 8 bytes file id  | ... |
+|                      | total tables      | (per table)      |     |
++----------------------+-------------------+------------------+-----+
+*/
+type Manifest struct {
+	levels [][]uint64
+}
+
+// newManifest creates a new instance of Manifest from the SSTables in the levels.
+func newManifest(levels [][]*sstable.TableReader) *Manifest {
+	manifest := &Manifest{levels: make([][]uint64, len(levels))}
+	for level, tables := range levels {
+		for _, table := range tables {
+			manifest.levels[level] = append(manifest.levels[level], table.FileId())
+		}
+	}
+	return manifest
+}
+
+// readManifest reads the Manifest from the directory. It returns false if the directory does not have a Manifest.
+// It returns errors.TooManyManifestLevelsErr if the Manifest has more than maxLevels levels.
+func readManifest(directory string, maxLevels int) (*Manifest, bool, error) {
+	buffer, err := os.ReadFile(filepath.Join(directory, manifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	manifest, err := decodeManifest(buffer, maxLevels)
+	if err != nil {
+		return nil, false, err
+	}
+	return manifest, true, nil
+}
+
+// write writes the Manifest to a temporary file, syncs it, renames it to the Manifest file and syncs the directory.
+func (manifest *Manifest) write(directory string) error {
+	temporaryFilePath := filepath.Join(directory, temporaryManifestFileName)
+	file, err := os.OpenFile(temporaryFilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(manifest.encode()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporaryFilePath, filepath.Join(directory, manifestFileName)); err != nil {
+		return err
+	}
+	return syncDirectory(directory)
+}
+
+// syncDirectory syncs the directory, which makes the creation (or the rename) of the files in the directory durable.
+// A directory can not be synced on windows, where the rename is made durable by the file system itself.
+func syncDirectory(directory string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// contains returns true if the Manifest has an SSTable with the fileId.
+func (manifest *Manifest) contains(fileId uint64) bool {
+	for _, fileIds := range manifest.levels {
+		for _, existingFileId := range fileIds {
+			if existingFileId == fileId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encode encodes the Manifest.
+func (manifest *Manifest) encode() []byte {
+	encoded := binary.LittleEndian.AppendUint32(nil, uint32(len(manifest.levels)))
+	for _, fileIds := range manifest.levels {
+		encoded = binary.LittleEndian.AppendUint32(encoded, uint32(len(fileIds)))
+		for _, fileId := range fileIds {
+			encoded = binary.LittleEndian.AppendUint64(encoded, fileId)
+		}
+	}
+	return encoded
+}
+
+// decodeManifest decodes the Manifest from the byte slice.
+// The total levels is validated before the levels are allocated: every level takes at least manifestUint32Size bytes,
+// and the Manifest can not have more than maxLevels levels.
+func decodeManifest(buffer []byte, maxLevels int) (*Manifest, error) {
+	if len(buffer) < manifestUint32Size {
+		return nil, errors.CorruptManifestErr
+	}
+	totalLevels, offset := int(binary.LittleEndian.Uint32(buffer)), manifestUint32Size
+	if totalLevels > (len(buffer)-offset)/manifestUint32Size {
+		return nil, errors.CorruptManifestErr
+	}
+	if totalLevels > maxLevels {
+		return nil, errors.TooManyManifestLevelsErr
+	}
+
+	manifest := &Manifest{levels: make([][]uint64, totalLevels)}
+	for level := 0; level < totalLevels; level++ {
+		if offset+manifestUint32Size > len(buffer) {
+			return nil, errors.CorruptManifestErr
+		}
+		totalTables := int(binary.LittleEndian.Uint32(buffer[offset:]))
+		offset = offset + manifestUint32Size
+		if offset+totalTables*manifestUint64Size > len(buffer) {
+			return nil, errors.CorruptManifestErr
+		}
+		for table := 0; table < totalTables; table++ {
+			manifest.levels[level] = append(manifest.levels[level], binary.LittleEndian.Uint64(buffer[offset:]))
+			offset = offset + manifestUint64Size
+		}
+	}
+	if offset != len(buffer) {
+		return nil, errors.CorruptManifestErr
+	}
+	return manifest, nil
+}
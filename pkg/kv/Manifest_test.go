@@ -0,0 +1,56 @@
+package kv
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"tinydb/pkg/kv/errors"
+)
+
+func TestWritesAndReadsTheManifest(t *testing.T) {
+	directory := t.TempDir()
+	manifest := &Manifest{levels: [][]uint64{{3, 4}, {1}, nil}}
+	assert.Nil(t, manifest.write(directory))
+
+	readManifest, ok, err := readManifest(directory, 7)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{3, 4}, readManifest.levels[0])
+	assert.Equal(t, []uint64{1}, readManifest.levels[1])
+	assert.Empty(t, readManifest.levels[2])
+}
+
+func TestReadsANonExistingManifest(t *testing.T) {
+	_, ok, err := readManifest(t.TempDir(), 7)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestManifestContainsAFileId(t *testing.T) {
+	manifest := &Manifest{levels: [][]uint64{{3, 4}, {1}}}
+	assert.True(t, manifest.contains(1))
+	assert.False(t, manifest.contains(2))
+}
+
+func TestDecodesACorruptManifest(t *testing.T) {
+	encoded := (&Manifest{levels: [][]uint64{{3, 4}}}).encode()
+
+	_, err := decodeManifest(encoded[:len(encoded)-1], 7)
+	assert.Equal(t, errors.CorruptManifestErr, err)
+}
+
+func TestDecodesAManifestWithACorruptTotalLevels(t *testing.T) {
+	encoded := (&Manifest{levels: [][]uint64{{3, 4}}}).encode()
+	binary.LittleEndian.PutUint32(encoded, math.MaxUint32)
+
+	_, err := decodeManifest(encoded, 7)
+	assert.Equal(t, errors.CorruptManifestErr, err)
+}
+
+func TestDecodesAManifestWithMoreLevelsThanMaxLevels(t *testing.T) {
+	encoded := (&Manifest{levels: [][]uint64{{3, 4}, {1}, nil}}).encode()
+
+	_, err := decodeManifest(encoded, 2)
+	assert.Equal(t, errors.TooManyManifestLevelsErr, err)
+}
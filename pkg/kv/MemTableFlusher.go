@@ -0,0 +1,97 @@
+package kv
+
+import (
+	"sync"
+	"time"
+	"tinydb/pkg/kv/mvcc"
+)
+
+// flushQueueSize is the number of immutable memtables that can wait to be flushed.
+// Submit blocks once the queue is full, which slows down the writers till the MemTableFlusher catches up.
+const flushQueueSize = 8
+
+// flushRetryInterval is the time the MemTableFlusher waits before retrying a failed flush, it doubles after every retry
+// upto maxFlushRetryInterval.
+const (
+	flushRetryInterval    = 50 * time.Millisecond
+	maxFlushRetryInterval = 5 * time.Second
+)
+
+// MemTableFlusher flushes the immutable memtables to SSTables in the background.
+// It is a single goroutine that reads the immutable memtables from the `flushChannel` and flushes them one after the other,
+// in the order they were made immutable.
+// A memtable that can not be flushed stays in the list of immutable memtables of the Workspace (and its WAL is not
+// removed), and the flush is retried till it succeeds or the MemTableFlusher is stopped. The later memtables wait for
+// it, because the SSTables of level 0 must be flushed in the order of their memtables.
+type MemTableFlusher struct {
+	flushChannel   chan *mvcc.MemTable
+	stopChannel    chan struct{}
+	stoppedChannel chan struct{}
+	stopOnce       sync.Once
+	workspace      *Workspace
+}
+
+// NewMemTableFlusher creates a new instance of MemTableFlusher. It is called once in the entire application.
+func NewMemTableFlusher(workspace *Workspace) *MemTableFlusher {
+	flusher := &MemTableFlusher{
+		flushChannel:   make(chan *mvcc.MemTable, flushQueueSize),
+		stopChannel:    make(chan struct{}),
+		stoppedChannel: make(chan struct{}),
+		workspace:      workspace,
+	}
+	go flusher.spin()
+	return flusher
+}
+
+// Submit submits the immutable memtable to be flushed.
+func (flusher *MemTableFlusher) Submit(memtable *mvcc.MemTable) {
+	flusher.flushChannel <- memtable
+}
+
+// Stop stops the MemTableFlusher, and returns after the flush in progress (if any) is done.
+// Stop can be called more than once, only the first call stops the MemTableFlusher.
+func (flusher *MemTableFlusher) Stop() {
+	flusher.stopOnce.Do(func() {
+		close(flusher.stopChannel)
+	})
+	<-flusher.stoppedChannel
+}
+
+// spin is invoked as a single goroutine [`go spin()`] and it reads either an event from `stopChannel` or an immutable
+// memtable from the `flushChannel`.
+func (flusher *MemTableFlusher) spin() {
+	defer close(flusher.stoppedChannel)
+	for {
+		select {
+		case memtable := <-flusher.flushChannel:
+			if !flusher.flushWithRetry(memtable) {
+				return
+			}
+		case <-flusher.stopChannel:
+			return
+		}
+	}
+}
+
+// flushWithRetry flushes the memtable, retrying a failed flush after the flushRetryInterval (doubled after every retry).
+// It returns true once the memtable is flushed, and false if the MemTableFlusher is stopped before that.
+func (flusher *MemTableFlusher) flushWithRetry(memtable *mvcc.MemTable) bool {
+	retryInterval := flushRetryInterval
+	for {
+		err := flusher.workspace.flush(memtable)
+		if err == nil {
+			return true
+		}
+		//TODO: Removes println in favor of logging
+		println("error while flushing memtable ", memtable.FileId(), err.Error())
+		select {
+		case <-time.After(retryInterval):
+		case <-flusher.stopChannel:
+			return false
+		}
+		retryInterval = retryInterval * 2
+		if retryInterval > maxFlushRetryInterval {
+			retryInterval = maxFlushRetryInterval
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"tinydb/pkg/kv/log"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable"
+	"tinydb/pkg/kv/vlog"
+)
+
+const (
+	walFileExtension   = ".wal"
+	tableFileExtension = ".sst"
+)
+
+// Open opens the Workspace in the DbDirectory, recovering the state left behind by the previous run.
+// Recovery involves the following:
+// 1. Opening all the SSTables listed in the Manifest, in their levels.
+// 2. Replaying all the WAL segments in the increasing order of their file ids into memtables.
+// 3. The memtable with the highest file id becomes the active memtable, the rest become immutable memtables and are sent to the MemTableFlusher.
+// An immutable memtable whose WAL segment is not sealed is sealed, so that no WAL segment other than the active one stays
+// open for writing.
+// 4. All the file ids handed out after recovery are greater than the file ids of the existing WALs and SSTables.
+// A WAL segment that has an SSTable with the same file id was flushed completely before the previous run stopped, so it is removed.
+// An SSTable that is not listed in the Manifest was either being flushed or being written by a compaction when the previous
+// run stopped, so it is removed (the WAL segment with the same file id, if any, is replayed).
+// If the DbDirectory does not have a Manifest, all the SSTables are opened in level 0. An SSTable that can not be opened
+// but has a WAL segment with the same file id is removed and the WAL segment is replayed.
+// If the DbDirectory does not contain any WAL segment, or if the latest WAL segment is sealed, a new active memtable is created.
+// The value log is opened with all its files, the values are appended to its latest file.
+func Open(options *option.Options) (*Workspace, error) {
+	walFileIds, err := existingFileIds(options.DbDirectory, walFileExtension)
+	if err != nil {
+		return nil, err
+	}
+	tableFileIds, err := existingFileIds(options.DbDirectory, tableFileExtension)
+	if err != nil {
+		return nil, err
+	}
+	manifest, hasManifest, err := readManifest(options.DbDirectory, options.MaxLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := &Workspace{
+		options:    options,
+		levels:     make([][]*sstable.TableReader, options.MaxLevels),
+		blockCache: newBlockCache(options),
+	}
+	lastFileId, hasFiles := lastFileIdOf(walFileIds, tableFileIds)
+	workspace.lastFileId.Store(lastFileId)
+
+	if hasManifest {
+		err = workspace.openTablesInManifest(manifest, tableFileIds)
+	} else {
+		err = workspace.openTablesInLevel0(tableFileIds, walFileIds)
+	}
+	if err != nil {
+		workspace.closeAllTables()
+		return nil, err
+	}
+
+	flushed := make(map[uint64]bool)
+	for _, tables := range workspace.levels {
+		for _, table := range tables {
+			flushed[table.FileId()] = true
+		}
+	}
+	var memtables []*mvcc.MemTable
+	for _, fileId := range walFileIds {
+		if flushed[fileId] {
+			if err := os.Remove(log.FilePath(fileId, options.DbDirectory)); err != nil {
+				workspace.closeAllTables()
+				return nil, err
+			}
+			continue
+		}
+		memtable, err := mvcc.RecoverMemTable(fileId, options)
+		if err != nil {
+			workspace.closeAllTables()
+			return nil, err
+		}
+		memtables = append(memtables, memtable)
+	}
+
+	if len(memtables) == 0 || memtables[len(memtables)-1].IsSealed() {
+		fileId := uint64(0)
+		if hasFiles {
+			fileId = workspace.lastFileId.Add(1)
+		}
+		memtable, err := mvcc.NewMemTable(fileId, options)
+		if err != nil {
+			workspace.closeAllTables()
+			return nil, err
+		}
+		memtables = append(memtables, memtable)
+	}
+	for _, memtable := range memtables[:len(memtables)-1] {
+		if memtable.IsSealed() {
+			continue
+		}
+		if err := memtable.Seal(); err != nil {
+			workspace.closeAllTables()
+			return nil, err
+		}
+	}
+
+	valueLog, err := vlog.Open(options.DbDirectory, options.ValueLogFileSizeInBytes)
+	if err != nil {
+		workspace.closeAllTables()
+		return nil, err
+	}
+	workspace.valueLog = valueLog
+	workspace.activeMemTable = memtables[len(memtables)-1]
+	workspace.immutableMemTables = memtables[:len(memtables)-1]
+	workspace.flusher = NewMemTableFlusher(workspace)
+	workspace.compactor = NewCompactor(workspace)
+	for _, memtable := range workspace.immutableMemTables {
+		workspace.flusher.Submit(memtable)
+	}
+	workspace.compactor.Trigger()
+	return workspace, nil
+}
+
+// openTablesInManifest opens the SSTables listed in the Manifest in their levels, and removes the SSTables that are not
+// listed in the Manifest.
+func (workspace *Workspace) openTablesInManifest(manifest *Manifest, tableFileIds []uint64) error {
+	for level, fileIds := range manifest.levels {
+		for level >= len(workspace.levels) {
+			workspace.levels = append(workspace.levels, nil)
+		}
+		for _, fileId := range fileIds {
+			table, err := workspace.openTable(fileId, level)
+			if err != nil {
+				return err
+			}
+			workspace.levels[level] = append(workspace.levels[level], table)
+		}
+	}
+	for _, fileId := range tableFileIds {
+		if manifest.contains(fileId) {
+			continue
+		}
+		if err := os.Remove(sstable.TableFilePath(fileId, workspace.options.DbDirectory)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openTablesInLevel0 opens all the SSTables in level 0. It is used when the DbDirectory does not have a Manifest.
+// An SSTable that can not be opened but has a WAL segment with the same file id is removed.
+func (workspace *Workspace) openTablesInLevel0(tableFileIds []uint64, walFileIds []uint64) error {
+	hasWAL := make(map[uint64]bool)
+	for _, fileId := range walFileIds {
+		hasWAL[fileId] = true
+	}
+	for _, fileId := range tableFileIds {
+		table, err := workspace.openTable(fileId, 0)
+		if err != nil {
+			if !hasWAL[fileId] {
+				return err
+			}
+			if err := os.Remove(sstable.TableFilePath(fileId, workspace.options.DbDirectory)); err != nil {
+				return err
+			}
+			continue
+		}
+		workspace.levels[0] = append(workspace.levels[0], table)
+	}
+	return nil
+}
+
+// closeAllTables closes all the SSTables. It is used when Open fails midway.
+func (workspace *Workspace) closeAllTables() {
+	for _, tables := range workspace.levels {
+		for _, table := range tables {
+			_ = table.Close()
+		}
+	}
+}
+
+// existingFileIds returns the file ids of all the files with the extension in the directory, in the increasing order.
+// Files that have the extension but not a numeric name are ignored.
+func existingFileIds(directory string, extension string) ([]uint64, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []uint64
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != extension {
+			continue
+		}
+		fileId, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), extension), 10, 64)
+		if err != nil {
+			continue
+		}
+		fileIds = append(fileIds, fileId)
+	}
+	sort.Slice(fileIds, func(i, j int) bool {
+		return fileIds[i] < fileIds[j]
+	})
+	return fileIds, nil
+}
+
+// lastFileIdOf returns the largest file id amongst the existing WAL segments and SSTables, and true if there was any file.
+func lastFileIdOf(walFileIds []uint64, tableFileIds []uint64) (uint64, bool) {
+	lastFileId, hasFiles := uint64(0), false
+	for _, fileIds := range [][]uint64{walFileIds, tableFileIds} {
+		if len(fileIds) > 0 {
+			hasFiles = true
+			if fileIds[len(fileIds)-1] > lastFileId {
+				lastFileId = fileIds[len(fileIds)-1]
+			}
+		}
+	}
+	return lastFileId, hasFiles
+}
@@ -0,0 +1,256 @@
+package kv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+	"tinydb/pkg/kv/log"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable"
+)
+
+func TestOpensAnEmptyDirectory(t *testing.T) {
+	workspace, err := Open(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	assert.Equal(t, uint64(0), workspace.activeMemTable.FileId())
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, false, ok)
+}
+
+func TestRecoversTheActiveMemtableFromTheWAL(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	workspace, _ := NewWorkspace(options)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.Delete(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	workspace.Stop()
+
+	recovered, err := Open(options)
+	assert.Nil(t, err)
+	defer recovered.Stop()
+
+	valueWithVersion, ok, _ := recovered.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = recovered.Get(mvcc.NewVersionedKey([]byte("HDD"), 5))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+
+	_, ok, _ = recovered.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, false, ok)
+}
+
+func TestRecoveredActiveMemtableContinuesToAcceptWrites(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	workspace, _ := NewWorkspace(options)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	workspace.Stop()
+
+	recovered, _ := Open(options)
+	_ = recovered.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state")))
+	recovered.Stop()
+
+	recoveredAgain, err := Open(options)
+	assert.Nil(t, err)
+	defer recoveredAgain.Stop()
+
+	valueWithVersion, ok, _ := recoveredAgain.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = recoveredAgain.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Solid state", string(valueWithVersion.ValueSlice()))
+}
+
+func TestRecoversImmutableMemtablesAndFlushesThem(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	_ = writeWAL(options, 3, mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = writeWAL(options, 7, mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state")))
+
+	workspace, err := Open(options)
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	assert.Equal(t, uint64(7), workspace.activeMemTable.FileId())
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Solid state", string(valueWithVersion.ValueSlice()))
+}
+
+func TestSealsTheWALOfRecoveredImmutableMemtables(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	_ = writeWAL(options, 3, mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = writeWAL(options, 7, mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state")))
+
+	workspace, err := Open(options)
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	workspace.lock.RLock()
+	immutableMemTables := workspace.immutableMemTables
+	workspace.lock.RUnlock()
+
+	assert.Equal(t, 1, len(immutableMemTables))
+	assert.Equal(t, true, immutableMemTables[0].IsSealed())
+	assert.Equal(t, false, workspace.activeMemTable.IsSealed())
+}
+
+func TestHandsOutFileIdsGreaterThanTheExistingFiles(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20)
+	_ = writeWAL(options, 3, mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+
+	workspace, _ := Open(options)
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state")))
+	assert.Equal(t, uint64(4), workspace.activeMemTable.FileId())
+}
+
+func TestRemovesTheWALOfAnAlreadyFlushedMemtable(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	_ = writeWAL(options, 1, mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+
+	builder := sstable.NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = builder.Build(1)
+
+	workspace, err := Open(options)
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	_, err = os.Stat(log.FilePath(1, options.DbDirectory))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, uint64(2), workspace.activeMemTable.FileId())
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestReplaysTheWALOfAPartiallyFlushedMemtable(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	_ = writeWAL(options, 1, mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = os.WriteFile(sstable.TableFilePath(1, options.DbDirectory), []byte("partial"), 0644)
+
+	workspace, err := Open(options)
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	_, err = os.Stat(sstable.TableFilePath(1, options.DbDirectory))
+	assert.True(t, os.IsNotExist(err))
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func writeWAL(options *option.Options, fileId uint64, key mvcc.VersionedKey, value mvcc.Value) error {
+	wal, err := log.NewWAL(fileId, options.DbDirectory)
+	if err != nil {
+		return err
+	}
+	return wal.Write(log.NewEntry(key.Encode(), value.Encode()))
+}
+
+func TestRecoversTheLastCommitTimestamp(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20)
+	workspace, _ := NewWorkspace(options)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("NVMe"), 3), mvcc.NewValue([]byte("Non-volatile memory")))
+	workspace.Stop()
+
+	recovered, err := Open(options)
+	assert.Nil(t, err)
+	defer recovered.Stop()
+
+	assert.Equal(t, uint64(3), recovered.LastCommitTimestamp())
+}
+
+func TestRecoversTheLastCommitTimestampFromSSTables(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	builder := sstable.NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 9), mvcc.NewValue([]byte("Hard disk")))
+	_ = builder.Build(1)
+
+	workspace, err := Open(options)
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	assert.Equal(t, uint64(9), workspace.LastCommitTimestamp())
+}
+
+func TestCreatesANewActiveMemtableGivenTheLatestWALIsSealed(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	memtable, _ := mvcc.NewMemTable(4, options)
+	_ = memtable.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = memtable.Seal()
+
+	workspace, err := Open(options)
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	assert.Equal(t, uint64(5), workspace.activeMemTable.FileId())
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestRecoversTheWorkspaceAfterClose(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	workspace, _ := NewWorkspace(options)
+	addTable(t, workspace, 0, entry("SSD", 1, "Solid state"))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk")))
+	assert.Nil(t, workspace.Close())
+
+	recovered, err := Open(options)
+	assert.Nil(t, err)
+	defer recovered.Close()
+
+	valueWithVersion, ok, _ := recovered.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = recovered.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Solid state", string(valueWithVersion.ValueSlice()))
+}
+
+func TestRecoversTheValuesInTheValueLogAfterClose(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetValueThresholdInBytes(16)
+	workspace, _ := NewWorkspace(options)
+	blob := bytes.Repeat([]byte("blob"), 25*1024)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue(blob))
+	assert.Nil(t, workspace.Close())
+
+	recovered, err := Open(options)
+	assert.Nil(t, err)
+	defer recovered.Close()
+
+	otherBlob := bytes.Repeat([]byte("other"), 25*1024)
+	_ = recovered.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue(otherBlob))
+
+	valueWithVersion, ok, _ := recovered.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.True(t, bytes.Equal(blob, valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = recovered.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, true, ok)
+	assert.True(t, bytes.Equal(otherBlob, valueWithVersion.ValueSlice()))
+}
@@ -1,42 +1,107 @@
 package kv
 
 import (
+	"sync"
+	"sync/atomic"
 	"tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable"
+	"tinydb/pkg/kv/vlog"
 )
 
-// Workspace
-// TODO: Check if we need any locks (during get), because put/delete will happen serially on the commit of a transaction;
-// TODO: but get can run concurrently.
-// Workspace is an abstraction that deals with active and all the immutable memtables.
+// Workspace is an abstraction that deals with active memtable, all the immutable memtables and the SSTables.
 // This abstraction will be instantiated once in the lifetime of the entire appplication.
+// Put/delete happen serially on the commit of a transaction, but get can run concurrently with put/delete, with the
+// MemTableFlusher and with the Compactor. The lock protects the slices of memtables and SSTables, not their contents.
+// SSTables are arranged in levels: levels[0] contains the SSTables flushed from the memtables (the oldest first), and
+// every other level contains SSTables with non-overlapping key ranges (in the increasing order of their keys).
+// compactionWatermark returns the timestamp till which all the transactions are done, refer to SetCompactionWatermark.
+// bloomFilterHits and bloomFilterMisses count the outcomes of the BloomFilter checks on the
+// read path, refer to BloomFilterStatistics.
+// blockCache caches the blocks of all the SSTables, it is shared by all the readers (nil if BlockCacheSizeInBytes is 0).
+// valueLog stores the values larger than ValueThresholdInBytes, and the memtables and the SSTables store the pointers to
+// such values (refer to separateValues). The pointers are resolved transparently by Get and the Iterator.
+// closed is set by the first Close (under the lock), the later calls to Close do nothing.
 type Workspace struct {
-	activeMemTable     *mvcc.MemTable
-	immutableMemTables []*mvcc.MemTable
-	options            *option.Options
+	lock                sync.RWMutex
+	activeMemTable      *mvcc.MemTable
+	immutableMemTables  []*mvcc.MemTable
+	levels              [][]*sstable.TableReader
+	flusher             *MemTableFlusher
+	compactor           *Compactor
+	compactionWatermark atomic.Pointer[func() uint64]
+	lastFileId          atomic.Uint64
+	blockCache          *sstable.BlockCache
+	valueLog            *vlog.ValueLog
+	options             *option.Options
+	closed              bool
+
+	bloomFilterHits   atomic.Uint64
+	bloomFilterMisses atomic.Uint64
+}
+
+// BloomFilterStatistics represents the outcomes of the BloomFilter checks done by Workspace.Get.
+// Hits is the number of times the BloomFilter said that the key may be present, so the SSTable was searched.
+// Misses is the number of times the BloomFilter said that the key is absent, so the SSTable was skipped.
+type BloomFilterStatistics struct {
+	Hits   uint64
+	Misses uint64
 }
 
 // NewWorkspace creates a new instance of Workspace.
-// Returns an error if the creation of NewMemtable fails.
+// Returns an error if the creation of NewMemtable (or the opening of the value log) fails.
+// NewWorkspace also starts the MemTableFlusher that flushes the immutable memtables to SSTables, and the Compactor that
+// compacts the SSTables across levels.
 func NewWorkspace(options *option.Options) (*Workspace, error) {
+	valueLog, err := vlog.Open(options.DbDirectory, options.ValueLogFileSizeInBytes)
+	if err != nil {
+		return nil, err
+	}
 	memtable, err := mvcc.NewMemTable(0, options)
 	if err != nil {
+		_ = valueLog.Close()
 		return nil, err
 	}
-	return &Workspace{
+	workspace := &Workspace{
 		activeMemTable: memtable,
+		levels:         make([][]*sstable.TableReader, options.MaxLevels),
+		blockCache:     newBlockCache(options),
+		valueLog:       valueLog,
 		options:        options,
-	}, nil
+	}
+	workspace.flusher = NewMemTableFlusher(workspace)
+	workspace.compactor = NewCompactor(workspace)
+	return workspace, nil
 }
 
 // PutOrUpdate puts or updates the key and the value pair in the active memtable.
 // It ensures that the memtable has the space to accommodate the incoming Key/Value pair.
 // Refer to IsFull() method inside tinydb/pkg/kv/mvcc.MemTable.
+// A value larger than ValueThresholdInBytes is stored in the value log, and the memtable stores the pointer to it.
 func (workspace *Workspace) PutOrUpdate(key mvcc.VersionedKey, value mvcc.Value) error {
 	if err := workspace.ensureRoom(); err != nil {
 		return err
 	}
-	return workspace.activeMemTable.PutOrUpdate(key, value)
+	pairs, err := workspace.separateValues([]mvcc.VersionedKeyValue{{Key: key, Value: value}})
+	if err != nil {
+		return err
+	}
+	return workspace.activeMemTable.PutOrUpdate(pairs[0].Key, pairs[0].Value)
+}
+
+// PutOrUpdateAll puts or updates all the key/value pairs in the active memtable, with a single write to its WAL.
+// It ensures the room once, before all the key/value pairs are written, so the active memtable can grow beyond
+// MemtableSizeInBytes by the size of the key/value pairs.
+// The values larger than ValueThresholdInBytes are appended to the value log (with a single write) before the WAL is written.
+func (workspace *Workspace) PutOrUpdateAll(pairs []mvcc.VersionedKeyValue) error {
+	if err := workspace.ensureRoom(); err != nil {
+		return err
+	}
+	pairs, err := workspace.separateValues(pairs)
+	if err != nil {
+		return err
+	}
+	return workspace.activeMemTable.PutOrUpdateAll(pairs)
 }
 
 // Delete deletes the key from the active memtable.
@@ -50,46 +115,336 @@ func (workspace *Workspace) Delete(key mvcc.VersionedKey) error {
 	return workspace.activeMemTable.Delete(key)
 }
 
-// Get returns a pair of (ValueWithVersion, bool) for the incoming key.
-// It returns (ValueWithVersion, true) if the value exists for the incoming key, else (nil, false).
-// It searches the active memtable and all the immutable memtables from the last index to 0 and tries
-// to find the key with the closest version.
-func (workspace *Workspace) Get(key mvcc.VersionedKey) (mvcc.ValueWithVersion, bool) {
-	valueWithMaxVersion := mvcc.EmptyValueWithZeroVersion()
-	for _, memtable := range workspace.allMemtables() {
-		value, ok := memtable.Get(key)
-		if ok {
-			if value.Version == key.Version {
-				return value, ok
-			}
-			if value.Version > valueWithMaxVersion.Version {
-				valueWithMaxVersion = value
-			}
+// SyncWAL syncs the value log and then the WAL of the active memtable, so that a synced WAL never points to a value
+// that is not synced.
+// The WAL of an immutable memtable does not need a sync, it is synced when the memtable is sealed.
+// SyncWAL must be called from the same goroutine that puts/deletes, because the active memtable changes on put/delete.
+func (workspace *Workspace) SyncWAL() error {
+	if err := workspace.valueLog.Sync(); err != nil {
+		return err
+	}
+	return workspace.activeMemTable.Sync()
+}
+
+// Options returns the options the Workspace was created with.
+func (workspace *Workspace) Options() *option.Options {
+	return workspace.options
+}
+
+// Get returns a triple of (ValueWithVersion, bool, error) for the incoming key.
+// It returns (ValueWithVersion, true, nil) if the value exists for the incoming key, else (nil, false, nil).
+// It searches the active memtable, all the immutable memtables from the last index to 0, then all the SSTables of level 0
+// from the latest to the oldest, and then the SSTable whose key range contains the key in each of the following levels.
+// All the versions of a key in a newer memtable (or SSTable) are greater than the versions of the same key in an older one,
+// so the search stops at the first memtable (or SSTable) that contains a version of the key that is less than or equal
+// to the incoming Version. If that version is deleted, the key does not exist for the reader.
+// The BloomFilter of an SSTable is checked before any of its data blocks is read.
+// Get returns the error if the value of the key can not be read from the value log (a missing file or a corrupt value),
+// so that a value that can not be read is never mistaken for a missing key.
+func (workspace *Workspace) Get(key mvcc.VersionedKey) (mvcc.ValueWithVersion, bool, error) {
+	memtables, tables := workspace.allMemtablesAndTables()
+	defer workspace.releaseTables(tables)
+
+	for _, memtable := range memtables {
+		if value, ok := memtable.GetIncludingDeleted(key); ok {
+			return workspace.existingValue(value)
 		}
 	}
-	//TODO: Read from SSTables arranged in levels
-	if valueWithMaxVersion.Version > 0 {
-		return valueWithMaxVersion, true
+	for _, table := range tables {
+		if !table.ContainsKey(key.KeySlice()) {
+			continue
+		}
+		if !table.MayContain(key.KeySlice()) {
+			workspace.bloomFilterMisses.Add(1)
+			continue
+		}
+		workspace.bloomFilterHits.Add(1)
+		if value, ok := table.Get(key); ok {
+			return workspace.existingValue(value)
+		}
 	}
-	return valueWithMaxVersion, false
+	return mvcc.EmptyValueWithZeroVersion(), false, nil
+}
+
+// Scan returns an Iterator over the keys in the range [start, end) that are visible at the version.
+// A nil end means that the range is not bounded on the right. It is ESSENTIAL to Close the Iterator after use.
+func (workspace *Workspace) Scan(start []byte, end []byte, version uint64) *Iterator {
+	return newIterator(workspace, start, end, version)
+}
+
+// LastCommitTimestamp returns the largest commitTimestamp of all the keys present in the memtables and the SSTables.
+// It is used by txn.Oracle to continue the timestamps after a restart.
+func (workspace *Workspace) LastCommitTimestamp() uint64 {
+	memtables, tables := workspace.allMemtablesAndTables()
+	defer workspace.releaseTables(tables)
+
+	lastCommitTimestamp := uint64(0)
+	for _, memtable := range memtables {
+		if memtable.MaxVersion() > lastCommitTimestamp {
+			lastCommitTimestamp = memtable.MaxVersion()
+		}
+	}
+	for _, table := range tables {
+		if table.MaxVersion() > lastCommitTimestamp {
+			lastCommitTimestamp = table.MaxVersion()
+		}
+	}
+	return lastCommitTimestamp
+}
+
+// BlockCacheStatistics returns the sstable.BlockCacheStatistics of the block cache since the Workspace was created.
+func (workspace *Workspace) BlockCacheStatistics() sstable.BlockCacheStatistics {
+	return workspace.blockCache.Statistics()
+}
+
+// BloomFilterStatistics returns the BloomFilterStatistics since the Workspace was created.
+func (workspace *Workspace) BloomFilterStatistics() BloomFilterStatistics {
+	return BloomFilterStatistics{
+		Hits:   workspace.bloomFilterHits.Load(),
+		Misses: workspace.bloomFilterMisses.Load(),
+	}
+}
+
+// SetCompactionWatermark sets the function that returns the timestamp till which all the transactions are done.
+// No transaction can read a version older than the latest version of a key that is less than or equal to the watermark,
+// so the Compactor drops such versions. It is set by txn.Oracle, and without a watermark the Compactor keeps all the versions.
+func (workspace *Workspace) SetCompactionWatermark(watermark func() uint64) {
+	workspace.compactionWatermark.Store(&watermark)
+}
+
+// Stop stops the MemTableFlusher and the Compactor. Stop (and Close) can be called more than once.
+func (workspace *Workspace) Stop() {
+	workspace.flusher.Stop()
+	workspace.compactor.Stop()
+}
+
+// Close stops the MemTableFlusher and the Compactor, syncs and closes the WAL of the active memtable, and releases all the SSTables.
+// The memtables are not flushed: the immutable memtables are sealed, and the active memtable is persisted in its WAL,
+// so all of them are recovered by Open. The value log is synced before the WAL, and closed after it.
+// No operation can be performed on a closed Workspace. Close can be called more than once, only the first call closes
+// the Workspace.
+func (workspace *Workspace) Close() error {
+	workspace.Stop()
+
+	workspace.lock.Lock()
+	defer workspace.lock.Unlock()
+
+	if workspace.closed {
+		return nil
+	}
+	workspace.closed = true
+
+	err := workspace.valueLog.Sync()
+	if closeErr := workspace.activeMemTable.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := workspace.valueLog.Close(); err == nil {
+		err = closeErr
+	}
+	for _, tables := range workspace.levels {
+		workspace.releaseTables(tables)
+	}
+	workspace.levels = make([][]*sstable.TableReader, len(workspace.levels))
+	return err
 }
 
 // ensureRoom ensures that the active memtable has the room to accommodate the incoming key/value pair.
-// If the active memtable is full, a new memtable is created and the previously active memtable is added to the list of immutable memtables.
-// TODO: Send the active memtable to be written to disk
+// If the active memtable is full, a new memtable is created and the previously active memtable is sealed and added to the list of immutable memtables.
+// Sealing records the largest commitTimestamp of the memtable in the footer of its WAL. The value log is synced before
+// sealing, because the sealed WAL (and the SSTable it is flushed to) may point to the values in the value log.
+// The previously active memtable is then sent to the MemTableFlusher to be written to disk.
+// If sealing fails, the full memtable stays active, but its WAL does not accept any more writes (refer to log.WAL.Seal),
+// so every subsequent write returns an error instead of writing after a (possibly written) footer.
 func (workspace *Workspace) ensureRoom() error {
 	if !workspace.activeMemTable.IsFull() {
 		return nil
 	}
-	memtable, err := mvcc.NewMemTable(1, workspace.options)
+	if err := workspace.valueLog.Sync(); err != nil {
+		return err
+	}
+	memtable, err := mvcc.NewMemTable(workspace.lastFileId.Add(1), workspace.options)
 	if err != nil {
 		return err
 	}
-	workspace.immutableMemTables = append(workspace.immutableMemTables, workspace.activeMemTable)
+	fullMemTable := workspace.activeMemTable
+	if err := fullMemTable.Seal(); err != nil {
+		memtable.RemoveWAL()
+		return err
+	}
+
+	workspace.lock.Lock()
+	workspace.immutableMemTables = append(workspace.immutableMemTables, fullMemTable)
 	workspace.activeMemTable = memtable
+	workspace.lock.Unlock()
+
+	workspace.flusher.Submit(fullMemTable)
+	return nil
+}
+
+// flush writes the immutable memtable to an SSTable that has the same file id as the memtable.
+// Once the SSTable is written, the memtable is replaced by the SSTable (in level 0) under the lock, so that the readers
+// either see the memtable or the SSTable. The Manifest is written before the replacement, and the WAL of the memtable
+// is removed after the replacement. The Compactor is triggered after every flush.
+func (workspace *Workspace) flush(memtable *mvcc.MemTable) error {
+	builder := sstable.NewSSTableBuilder(workspace.options)
+	memtable.ForEach(func(key mvcc.VersionedKey, value mvcc.Value) {
+		builder.Add(key, value)
+	})
+
+	var table *sstable.TableReader
+	if !builder.IsEmpty() {
+		if err := builder.Build(memtable.FileId()); err != nil {
+			return err
+		}
+		reader, err := workspace.openTable(memtable.FileId(), 0)
+		if err != nil {
+			return err
+		}
+		table = reader
+	}
+
+	workspace.lock.Lock()
+	if table != nil {
+		levels := workspace.copyOfLevels()
+		levels[0] = append(levels[0], table)
+		if err := newManifest(levels).write(workspace.options.DbDirectory); err != nil {
+			workspace.lock.Unlock()
+			table.RemoveOnRelease()
+			_ = table.DecrementReference()
+			return err
+		}
+		workspace.levels = levels
+	}
+	updatedImmutableMemTables := workspace.immutableMemTables[:0]
+	for _, immutableMemTable := range workspace.immutableMemTables {
+		if immutableMemTable != memtable {
+			updatedImmutableMemTables = append(updatedImmutableMemTables, immutableMemTable)
+		}
+	}
+	workspace.immutableMemTables = updatedImmutableMemTables
+	workspace.lock.Unlock()
+
+	memtable.RemoveWAL()
+	workspace.compactor.Trigger()
 	return nil
 }
 
+// openTable opens the SSTable with the fileId that is placed in the level, with the block cache of the Workspace.
+// The index block and the bloom filter of an SSTable in level 0 are pinned in the block cache with PinLevel0IndexAndFilter.
+func (workspace *Workspace) openTable(fileId uint64, level int) (*sstable.TableReader, error) {
+	pinIndexAndFilter := level == 0 && workspace.options.PinLevel0IndexAndFilter
+	return sstable.NewTableReaderWithCache(fileId, workspace.options, workspace.blockCache, pinIndexAndFilter)
+}
+
+// newBlockCache creates the block cache with the BlockCacheSizeInBytes, nil if BlockCacheSizeInBytes is 0.
+func newBlockCache(options *option.Options) *sstable.BlockCache {
+	if options.BlockCacheSizeInBytes == 0 {
+		return nil
+	}
+	return sstable.NewBlockCache(options.BlockCacheSizeInBytes)
+}
+
+// existingValue returns (nil, false, nil) if the value is deleted, else (value, true, nil).
+// A value pointer is resolved to the value from the value log, and the error is returned if the value can not be read.
+func (workspace *Workspace) existingValue(value mvcc.ValueWithVersion) (mvcc.ValueWithVersion, bool, error) {
+	if value.IsDeleted() {
+		return mvcc.EmptyValueWithZeroVersion(), false, nil
+	}
+	resolved, err := workspace.resolveValue(value)
+	if err != nil {
+		return mvcc.EmptyValueWithZeroVersion(), false, err
+	}
+	return resolved, true, nil
+}
+
+// separateValues appends the values larger than ValueThresholdInBytes to the value log (with a single write), and returns
+// the pairs with such values replaced by the pointers to them. The incoming pairs are not changed.
+// The pairs are returned as is if ValueThresholdInBytes is 0, or if none of the values is larger than the threshold.
+func (workspace *Workspace) separateValues(pairs []mvcc.VersionedKeyValue) ([]mvcc.VersionedKeyValue, error) {
+	threshold := workspace.options.ValueThresholdInBytes
+	if threshold == 0 {
+		return pairs, nil
+	}
+	var positions []int
+	var values [][]byte
+	for position, pair := range pairs {
+		if !pair.Value.IsDeleted() && uint64(len(pair.Value.ValueSlice())) > threshold {
+			positions = append(positions, position)
+			values = append(values, pair.Value.ValueSlice())
+		}
+	}
+	if len(values) == 0 {
+		return pairs, nil
+	}
+	pointers, err := workspace.valueLog.Append(values)
+	if err != nil {
+		return nil, err
+	}
+	separated := append([]mvcc.VersionedKeyValue(nil), pairs...)
+	for index, position := range positions {
+		separated[position].Value = mvcc.NewValuePointer(pointers[index].Encode())
+	}
+	return separated, nil
+}
+
+// resolveValue returns the value as is, unless it is a value pointer, in which case the value is read from the value log.
+func (workspace *Workspace) resolveValue(value mvcc.ValueWithVersion) (mvcc.ValueWithVersion, error) {
+	if !value.IsValuePointer() {
+		return value, nil
+	}
+	pointer, err := vlog.DecodeValuePointer(value.ValueSlice())
+	if err != nil {
+		return mvcc.EmptyValueWithZeroVersion(), err
+	}
+	resolved, err := workspace.valueLog.Read(pointer)
+	if err != nil {
+		return mvcc.EmptyValueWithZeroVersion(), err
+	}
+	return mvcc.NewValueWithVersion(mvcc.NewValue(resolved), value.Version), nil
+}
+
+// allMemtablesAndTables returns a consistent view of all the memtables and all the SSTables.
+// Refer to allMemtables for the order of memtables. SSTables of level 0 are placed in the order of the latest SSTable
+// first to the oldest SSTable last, followed by the SSTables of level 1, level 2 and so on.
+// A reference is acquired on every SSTable, so that a compaction does not close the SSTables while they are being read.
+// It is ESSENTIAL to call releaseTables once the SSTables are read.
+func (workspace *Workspace) allMemtablesAndTables() ([]*mvcc.MemTable, []*sstable.TableReader) {
+	workspace.lock.RLock()
+	defer workspace.lock.RUnlock()
+
+	var tables []*sstable.TableReader
+	for index := len(workspace.levels[0]) - 1; index >= 0; index-- {
+		tables = append(tables, workspace.levels[0][index])
+	}
+	for _, levelTables := range workspace.levels[1:] {
+		tables = append(tables, levelTables...)
+	}
+	for _, table := range tables {
+		table.IncrementReference()
+	}
+	return workspace.allMemtables(), tables
+}
+
+// releaseTables releases the references acquired by allMemtablesAndTables.
+func (workspace *Workspace) releaseTables(tables []*sstable.TableReader) {
+	for _, table := range tables {
+		if err := table.DecrementReference(); err != nil {
+			//TODO: Removes println in favor of logging
+			println("error while releasing sstable ", table.FileId(), err.Error())
+		}
+	}
+}
+
+// copyOfLevels returns a copy of the levels. The copy is changed and written to the Manifest, and it replaces the
+// levels only if the Manifest is written. It is called with the lock held.
+func (workspace *Workspace) copyOfLevels() [][]*sstable.TableReader {
+	levels := make([][]*sstable.TableReader, len(workspace.levels))
+	for level, tables := range workspace.levels {
+		levels[level] = append([]*sstable.TableReader(nil), tables...)
+	}
+	return levels
+}
+
 // allMemtables returns a slice of all the memtables includes: the currently active memtable and all the immutable memtables.
 // the currently active memtable is placed in the index 0 of the allMemtables slice
 // all the other immutable memtables are placed in the order of the latest immutable memtable first to
@@ -109,6 +464,9 @@ func (workspace *Workspace) allMemtables() []*mvcc.MemTable {
 
 // RemoveAllWAL removes the WAL of all the memtables. It is ONLY used from tests.
 func (workspace *Workspace) RemoveAllWAL() {
+	workspace.lock.RLock()
+	defer workspace.lock.RUnlock()
+
 	workspace.activeMemTable.RemoveWAL()
 	for _, memtable := range workspace.immutableMemTables {
 		memtable.RemoveWAL()
@@ -1,10 +1,17 @@
 package kv
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
+	"time"
+	"tinydb/pkg/kv/log"
 	"tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable"
+	"tinydb/pkg/kv/vlog"
+	vlogErrors "tinydb/pkg/kv/vlog/errors"
 )
 
 func TestWorkspacePutAndGet(t *testing.T) {
@@ -14,7 +21,7 @@ func TestWorkspacePutAndGet(t *testing.T) {
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
 }
@@ -26,7 +33,7 @@ func TestWorkspaceGetTheValueWithTheNearestVersion(t *testing.T) {
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 10))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 10))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
 }
@@ -37,7 +44,7 @@ func TestWorkspaceGetANonExistingKey(t *testing.T) {
 
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
 
-	_, ok := workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
 	assert.Equal(t, false, ok)
 }
 
@@ -49,7 +56,7 @@ func TestWorkspaceDeleteAndGetADeletedKey(t *testing.T) {
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
 	_ = workspace.Delete(mvcc.NewVersionedKey([]byte("HDD"), 3))
 
-	_, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
 	assert.Equal(t, false, ok)
 }
 
@@ -61,39 +68,266 @@ func TestWorkspaceDeleteAndGetAKeyWithADifferentVersion(t *testing.T) {
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
 	_ = workspace.Delete(mvcc.NewVersionedKey([]byte("HDD"), 2))
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
 }
 
 func TestMemtableFull(t *testing.T) {
-	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(".").SetMemtableSizeInBytes(20))
-	defer workspace.RemoveAllWAL()
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20))
+	defer workspace.Stop()
 
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
 
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk")))
-	assert.Equal(t, 1, len(workspace.immutableMemTables))
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
 }
 
-func TestWorkspaceGetAcrossAllTheMemtables(t *testing.T) {
-	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(".").SetMemtableSizeInBytes(20))
-	defer workspace.RemoveAllWAL()
+func TestWorkspaceGetAcrossTheMemtableAndTheSSTable(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20))
+	defer workspace.Stop()
 
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
 	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
 
-	assert.Equal(t, 1, len(workspace.immutableMemTables))
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Solid state drive", string(valueWithVersion.ValueSlice()))
 }
+
+func TestWorkspaceGetADeletedKeyGivenTheOlderVersionIsInTheSSTable(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.Delete(mvcc.NewVersionedKey([]byte("HDD"), 2))
+
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, false, ok)
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+}
+
+func TestWorkspaceRemovesTheWALOfAFlushedMemtable(t *testing.T) {
+	directory := t.TempDir()
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetMemtableSizeInBytes(20))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
+
+	_, err := os.Stat(log.FilePath(0, directory))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(sstable.TableFilePath(0, directory))
+	assert.Nil(t, err)
+}
+
+func totalImmutableMemtablesAndTables(workspace *Workspace) [2]int {
+	workspace.lock.RLock()
+	defer workspace.lock.RUnlock()
+	totalTables := 0
+	for _, tables := range workspace.levels {
+		totalTables = totalTables + len(tables)
+	}
+	return [2]int{len(workspace.immutableMemTables), totalTables}
+}
+
+func TestWorkspaceCountsTheBloomFilterHitsAndMisses(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 1, entry("HDD", 1, "Hard disk"), entry("SSD", 1, "Solid state"))
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+
+	_, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("NVMe"), 1))
+	assert.Equal(t, false, ok)
+
+	assert.Equal(t, BloomFilterStatistics{Hits: 1, Misses: 1}, workspace.BloomFilterStatistics())
+}
+
+func TestWorkspacePutsAllTheKeys(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdateAll([]mvcc.VersionedKeyValue{
+		{Key: mvcc.NewVersionedKey([]byte("HDD"), 1), Value: mvcc.NewValue([]byte("Hard disk"))},
+		{Key: mvcc.NewVersionedKey([]byte("HDD"), 2), Value: mvcc.NewValue([]byte("Hard disk drive"))},
+	})
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+}
+
+func TestWorkspaceServesTheRepeatedReadsOfAnSSTableFromTheBlockCache(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	addTable(t, workspace, 1, entry("HDD", 1, "Hard disk"), entry("SSD", 1, "Solid state"))
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	before := workspace.BlockCacheStatistics()
+
+	_, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	assert.Equal(t, true, ok)
+	after := workspace.BlockCacheStatistics()
+
+	assert.Equal(t, before.Misses, after.Misses)
+	assert.True(t, after.Hits > before.Hits)
+}
+
+func TestWorkspacePinsTheIndexBlockAndFilterOfLevel0Tables(t *testing.T) {
+	// The block cache is too small for any block, so only the pinned blocks are served from it.
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetBlockCacheSizeInBytes(16).SetPinLevel0IndexAndFilter(true)
+	workspace, _ := NewWorkspace(options)
+	defer workspace.Stop()
+
+	addTable(t, workspace, 0, entry("HDD", 1, "Hard disk"))
+	addTable(t, workspace, 1, entry("SSD", 1, "Solid state"))
+
+	before := workspace.BlockCacheStatistics()
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	level0 := workspace.BlockCacheStatistics()
+
+	// The filter and the index block of the level 0 table are hits, the data block is a miss.
+	assert.Equal(t, uint64(2), level0.Hits-before.Hits)
+	assert.Equal(t, uint64(1), level0.Misses-before.Misses)
+
+	_, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	assert.Equal(t, true, ok)
+	level1 := workspace.BlockCacheStatistics()
+
+	// The filter, the index block and the data block of the level 1 table are misses.
+	assert.Equal(t, level0.Hits, level1.Hits)
+	assert.Equal(t, uint64(3), level1.Misses-level0.Misses)
+}
+
+func TestWorkspaceStoresTheValuesAboveTheThresholdInTheValueLog(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetValueThresholdInBytes(16))
+	defer workspace.Stop()
+
+	blob := bytes.Repeat([]byte("blob"), 25*1024)
+	_ = workspace.PutOrUpdateAll([]mvcc.VersionedKeyValue{
+		{Key: mvcc.NewVersionedKey([]byte("HDD"), 1), Value: mvcc.NewValue([]byte("Hard disk"))},
+		{Key: mvcc.NewVersionedKey([]byte("SSD"), 1), Value: mvcc.NewValue(blob)},
+	})
+
+	value, _ := workspace.activeMemTable.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	assert.True(t, value.IsValuePointer())
+
+	value, _ = workspace.activeMemTable.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.False(t, value.IsValuePointer())
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	assert.Equal(t, true, ok)
+	assert.True(t, bytes.Equal(blob, valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestWorkspaceResolvesTheValuePointersOfAnSSTable(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMemtableSizeInBytes(20).SetValueThresholdInBytes(16))
+	defer workspace.Stop()
+
+	blob := bytes.Repeat([]byte("blob"), 25*1024)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue(blob))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, workspace.levels[0][0].SizeInBytes() < uint64(len(blob)))
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.True(t, bytes.Equal(blob, valueWithVersion.ValueSlice()))
+
+	iterator := workspace.Scan([]byte("HDD"), nil, 1)
+	defer iterator.Close()
+
+	assert.True(t, iterator.IsValid())
+	assert.True(t, bytes.Equal(blob, iterator.Value().ValueSlice()))
+	assert.Nil(t, iterator.Err())
+}
+
+func TestWorkspaceReturnsTheErrorOfReadingACorruptValueFromTheValueLog(t *testing.T) {
+	directory := t.TempDir()
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetValueThresholdInBytes(16))
+	defer workspace.Stop()
+
+	blob := bytes.Repeat([]byte("blob"), 25*1024)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue(blob))
+
+	file, _ := os.OpenFile(vlog.FilePath(0, directory), os.O_RDWR, 0644)
+	_, _ = file.WriteAt([]byte("corrupt"), 64)
+	_ = file.Close()
+
+	_, ok, err := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, vlogErrors.CorruptValueErr, err)
+}
+
+func TestWorkspaceStopsAndClosesMoreThanOnce(t *testing.T) {
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+
+	workspace.Stop()
+	workspace.Stop()
+	assert.Nil(t, workspace.Close())
+	assert.Nil(t, workspace.Close())
+}
+
+func TestWorkspaceRetriesAFailedFlush(t *testing.T) {
+	directory := t.TempDir()
+	workspace, _ := NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetMemtableSizeInBytes(20))
+	defer workspace.Stop()
+
+	// The SSTable of the first memtable can not be created while a directory occupies its path.
+	assert.Nil(t, os.Mkdir(sstable.TableFilePath(0, directory), 0755))
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, [2]int{1, 0}, totalImmutableMemtablesAndTables(workspace))
+
+	assert.Nil(t, os.Remove(sstable.TableFilePath(0, directory)))
+	assert.Eventually(t, func() bool {
+		return totalImmutableMemtablesAndTables(workspace) == [2]int{0, 1}
+	}, time.Second, 5*time.Millisecond)
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+}
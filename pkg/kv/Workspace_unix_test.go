@@ -0,0 +1,30 @@
+//go:build unix
+
+package kv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"tinydb/pkg/kv/log"
+	"tinydb/pkg/kv/log/errors"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+)
+
+// The WAL is a FIFO in the following test: the writes to a FIFO succeed (so the footer is written), but fsync on a FIFO fails.
+func TestWorkspaceDoesNotWriteAfterTheFooterOfAWALWhoseSealFails(t *testing.T) {
+	directory := t.TempDir()
+	assert.Nil(t, syscall.Mkfifo(log.FilePath(0, directory), 0644))
+
+	workspace, err := NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetMemtableSizeInBytes(20))
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	assert.Nil(t, workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk drive"))))
+	assert.NotNil(t, workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state drive"))))
+	assert.Equal(t, errors.SealedWALErr, workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state drive"))))
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, false, ok)
+}
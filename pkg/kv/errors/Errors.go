@@ -0,0 +1,6 @@
+package errors
+
+import "errors"
+
+var CorruptManifestErr = errors.New("manifest is corrupt, it can not be decoded")
+var TooManyManifestLevelsErr = errors.New("manifest has more levels than the MaxLevels option")
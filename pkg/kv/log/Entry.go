@@ -3,42 +3,63 @@ package log
 import (
 	"bytes"
 	"encoding/binary"
+	"hash/crc32"
 	"io"
+	"tinydb/pkg/kv/log/errors"
 	"unsafe"
 )
 
 const (
-	KeyLength    = unsafe.Sizeof(uint32(0))
-	ValueLength  = unsafe.Sizeof(uint32(0))
-	HeaderLength = KeyLength + ValueLength
+	ChecksumLength = unsafe.Sizeof(uint32(0))
+	KeyLength      = unsafe.Sizeof(uint32(0))
+	ValueLength    = unsafe.Sizeof(uint32(0))
+	HeaderLength   = ChecksumLength + KeyLength + ValueLength
 )
 
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Header precedes every Entry in the WAL.
+// The checksum is the CRC32C (Castagnoli) of the key length, the value length, the key and the value. It allows
+// detecting a torn write (or a flipped bit) while replaying the WAL.
+/*
+Structure of the Header.
++-------------------+--------------------+----------------------+
+| 4 bytes checksum  | 4 bytes key length | 4 bytes value length |
++-------------------+--------------------+----------------------+
+*/
 type Header struct {
+	checksum    uint32
 	keyLength   uint32
 	valueLength uint32
 }
 
 func (header *Header) encode() []byte {
 	encodedHeader := make([]byte, HeaderLength)
-	binary.LittleEndian.PutUint32(encodedHeader[:], header.keyLength)
-	binary.LittleEndian.PutUint32(encodedHeader[KeyLength:], header.valueLength)
+	binary.LittleEndian.PutUint32(encodedHeader[:], header.checksum)
+	binary.LittleEndian.PutUint32(encodedHeader[ChecksumLength:], header.keyLength)
+	binary.LittleEndian.PutUint32(encodedHeader[ChecksumLength+KeyLength:], header.valueLength)
 	return encodedHeader
 }
 
 func (header *Header) decodeFrom(reader io.Reader) error {
-	keyLengthBytes, valueLengthBytes := make([]byte, KeyLength), make([]byte, ValueLength)
-	if _, err := reader.Read(keyLengthBytes); err != nil {
-		return err
-	}
-	if _, err := reader.Read(valueLengthBytes); err != nil {
+	encodedHeader := make([]byte, HeaderLength)
+	if _, err := io.ReadFull(reader, encodedHeader); err != nil {
 		return err
 	}
-	header.keyLength = binary.LittleEndian.Uint32(keyLengthBytes)
-	header.valueLength = binary.LittleEndian.Uint32(valueLengthBytes)
+	header.checksum = binary.LittleEndian.Uint32(encodedHeader)
+	header.keyLength = binary.LittleEndian.Uint32(encodedHeader[ChecksumLength:])
+	header.valueLength = binary.LittleEndian.Uint32(encodedHeader[ChecksumLength+KeyLength:])
 
 	return nil
 }
 
+// checksumOf returns the CRC32C of the key length and the value length of the header, followed by the key and the value.
+func (header *Header) checksumOf(key, value []byte) uint32 {
+	checksum := crc32.Update(0, castagnoliTable, header.encode()[ChecksumLength:])
+	checksum = crc32.Update(checksum, castagnoliTable, key)
+	return crc32.Update(checksum, castagnoliTable, value)
+}
+
 type Entry struct {
 	key   []byte
 	value []byte
@@ -51,11 +72,22 @@ func NewEntry(key, value []byte) *Entry {
 	}
 }
 
+// Key returns the key of the Entry.
+func (entry *Entry) Key() []byte {
+	return entry.key
+}
+
+// Value returns the value of the Entry.
+func (entry *Entry) Value() []byte {
+	return entry.value
+}
+
 func (entry *Entry) Encode() ([]byte, error) {
 	header := &Header{
 		keyLength:   uint32(len(entry.key)),
 		valueLength: uint32(len(entry.value)),
 	}
+	header.checksum = header.checksumOf(entry.key, entry.value)
 	//TODO: use pool
 	encoded := &bytes.Buffer{}
 	if _, err := entry.writeTo(header.encode(), encoded); err != nil {
@@ -74,14 +106,19 @@ func (entry *Entry) writeTo(part []byte, buffer *bytes.Buffer) (int, error) {
 	return buffer.Write(part)
 }
 
+// decodeFrom decodes the key and the value of the Entry from the reader, and verifies them against the checksum of the header.
+// It returns errors.CorruptEntryErr if the checksum does not match.
 func (entry *Entry) decodeFrom(header *Header, reader io.Reader) error {
 	keyBytes, valueBytes := make([]byte, header.keyLength), make([]byte, header.valueLength)
-	if _, err := reader.Read(keyBytes); err != nil {
+	if _, err := io.ReadFull(reader, keyBytes); err != nil {
 		return err
 	}
-	if _, err := reader.Read(valueBytes); err != nil {
+	if _, err := io.ReadFull(reader, valueBytes); err != nil {
 		return err
 	}
+	if header.checksumOf(keyBytes, valueBytes) != header.checksum {
+		return errors.CorruptEntryErr
+	}
 	entry.key = keyBytes
 	entry.value = valueBytes
 
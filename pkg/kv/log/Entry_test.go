@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"strings"
 	"testing"
+	"tinydb/pkg/kv/log/errors"
 )
 
 func TestHeaderEncodeAndDecode(t *testing.T) {
@@ -50,7 +51,7 @@ func TestEntryEncodeAndDecodeWithError(t *testing.T) {
 	entry := NewEntry([]byte("storage"), []byte("LSM"))
 	encodedEntry, _ := entry.Encode()
 
-	reader := strings.NewReader(string(encodedEntry[0:3]))
+	reader := strings.NewReader(string(encodedEntry[0 : HeaderLength+3]))
 
 	decodedEntry := new(Entry)
 	decodedHeader := new(Header)
@@ -59,3 +60,18 @@ func TestEntryEncodeAndDecodeWithError(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestEntryDecodeWithAChecksumMismatch(t *testing.T) {
+	entry := NewEntry([]byte("storage"), []byte("LSM"))
+	encodedEntry, _ := entry.Encode()
+	encodedEntry[len(encodedEntry)-1] ^= 0xFF
+
+	reader := strings.NewReader(string(encodedEntry))
+
+	decodedEntry := new(Entry)
+	decodedHeader := new(Header)
+	_ = decodedHeader.decodeFrom(reader)
+	err := decodedEntry.decodeFrom(decodedHeader, reader)
+
+	assert.Equal(t, errors.CorruptEntryErr, err)
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// footerMagicNumber is placed at the end of every sealed WAL segment. It spells "tinydbWL".
+const footerMagicNumber = uint64(0x7469_6e79_6462_574c)
+
+const FooterLength = unsafe.Sizeof(uint64(0)) + unsafe.Sizeof(uint64(0))
+
+// Footer is written at the end of a WAL segment when the segment is sealed (when its memtable becomes full).
+// Footer records the last (largest) commit timestamp of all the entries present in the segment, so that the
+// timestamp can be recovered without reading all the entries of the segment.
+/*
+Structure of the Footer.
++-----------------------------------+-----------------------+
+| 8 bytes last commit timestamp     | 8 bytes magic number  |
++-----------------------------------+-----------------------+
+*/
+type Footer struct {
+	lastCommitTimestamp uint64
+}
+
+// encode encodes the Footer along with the magic number.
+func (footer Footer) encode() []byte {
+	encoded := make([]byte, FooterLength)
+	binary.LittleEndian.PutUint64(encoded, footer.lastCommitTimestamp)
+	binary.LittleEndian.PutUint64(encoded[unsafe.Sizeof(uint64(0)):], footerMagicNumber)
+	return encoded
+}
+
+// decodeFrom decodes the Footer from the byte slice. It returns false if the byte slice does not end with the magic number,
+// which means that the segment was not sealed.
+func (footer *Footer) decodeFrom(part []byte) bool {
+	if uintptr(len(part)) != FooterLength || binary.LittleEndian.Uint64(part[unsafe.Sizeof(uint64(0)):]) != footerMagicNumber {
+		return false
+	}
+	footer.lastCommitTimestamp = binary.LittleEndian.Uint64(part)
+	return true
+}
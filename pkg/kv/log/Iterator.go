@@ -2,33 +2,64 @@ package log
 
 import (
 	"bufio"
-	"os"
+	"io"
+	"tinydb/pkg/kv/log/errors"
 )
 
 type BufferedReader struct {
 	*bufio.Reader
 }
 
+// WalIterator iterates over the entries of a WAL segment.
+// WalIterator stops at the first corrupt or truncated entry: Next returns errors.CorruptEntryErr (or errors.TruncatedEntryErr)
+// for that entry and all the entries after it. ValidOffset returns the offset till which the entries are valid, which
+// is used to truncate the segment during recovery.
 type WalIterator struct {
-	reader *BufferedReader
+	reader      *BufferedReader
+	size        int64
+	validOffset int64
+	err         error
 }
 
-func NewBufferedReader(file *os.File) *BufferedReader {
+func NewBufferedReader(reader io.Reader) *BufferedReader {
 	return &BufferedReader{
-		bufio.NewReader(file),
+		bufio.NewReader(reader),
 	}
 }
 
+// Next returns the next Entry, or io.EOF if there are no more entries.
+// The lengths in the Header are checked against the remaining size of the segment before the key and the value are read,
+// so a garbage length does not allocate a huge buffer.
 func (iterator *WalIterator) Next() (*Entry, error) {
+	if iterator.err != nil {
+		return nil, iterator.err
+	}
 	header := new(Header)
-	err := header.decodeFrom(iterator.reader)
-	if err != nil {
-		return nil, err
+	if err := header.decodeFrom(iterator.reader); err != nil {
+		return nil, iterator.stopWith(err)
+	}
+	entrySize := int64(HeaderLength) + int64(header.keyLength) + int64(header.valueLength)
+	if iterator.validOffset+entrySize > iterator.size {
+		return nil, iterator.stopWith(errors.TruncatedEntryErr)
 	}
 	entry := new(Entry)
-	err = entry.decodeFrom(header, iterator.reader)
-	if err != nil {
-		return nil, err
+	if err := entry.decodeFrom(header, iterator.reader); err != nil {
+		return nil, iterator.stopWith(err)
 	}
+	iterator.validOffset = iterator.validOffset + entrySize
 	return entry, nil
 }
+
+// ValidOffset returns the offset of the end of the last valid Entry returned by Next.
+func (iterator *WalIterator) ValidOffset() int64 {
+	return iterator.validOffset
+}
+
+// stopWith stops the WalIterator. A partially read Header or Entry is reported as errors.TruncatedEntryErr.
+func (iterator *WalIterator) stopWith(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		err = errors.TruncatedEntryErr
+	}
+	iterator.err = err
+	return err
+}
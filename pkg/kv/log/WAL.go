@@ -2,34 +2,96 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"tinydb/pkg/kv/log/errors"
 )
 
+// WAL is a segment of the write-ahead log.
+// sealed is set once Seal is attempted, refer to Seal.
 type WAL struct {
+	filePath              string
 	writableFileHandle    *os.File
 	readableFileHandle    *os.File
 	currentWritableOffset uint64
+	entriesSize           int64
+	footer                *Footer
+	sealed                bool
 }
 
 func NewWAL(fileId uint64, directory string) (*WAL, error) {
-	filePath := directory + fmt.Sprintf("%v.wal", fileId)
+	filePath := FilePath(fileId, directory)
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
-	return &WAL{writableFileHandle: file, currentWritableOffset: 0}, nil
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &WAL{filePath: filePath, writableFileHandle: file, currentWritableOffset: uint64(stat.Size())}, nil
 }
 
+// NewReadonlyWAL opens an existing WAL segment for reading.
+// If the segment is sealed, the Footer is decoded and the Iterator stops before the Footer.
 func NewReadonlyWAL(fileId uint64, directory string) (*WAL, error) {
-	filePath := directory + fmt.Sprintf("%v.wal", fileId)
+	filePath := FilePath(fileId, directory)
 	file, err := os.OpenFile(filePath, os.O_RDONLY, 0444)
 	if err != nil {
 		return nil, err
 	}
-	return &WAL{readableFileHandle: file}, nil
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	wal := &WAL{filePath: filePath, readableFileHandle: file, entriesSize: stat.Size()}
+	if stat.Size() >= int64(FooterLength) {
+		footerBytes := make([]byte, FooterLength)
+		if _, err := file.ReadAt(footerBytes, stat.Size()-int64(FooterLength)); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		footer := new(Footer)
+		if footer.decodeFrom(footerBytes) {
+			wal.footer = footer
+			wal.entriesSize = stat.Size() - int64(FooterLength)
+		}
+	}
+	return wal, nil
 }
 
+// Truncate truncates the WAL segment identified by the fileId in the directory to the size.
+// It is used during recovery to remove the entries after the last valid entry.
+func Truncate(fileId uint64, directory string, size int64) error {
+	file, err := os.OpenFile(FilePath(fileId, directory), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(size); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// FilePath returns the path of the WAL file identified by the fileId in the directory.
+func FilePath(fileId uint64, directory string) string {
+	return filepath.Join(directory, fmt.Sprintf("%v.wal", fileId))
+}
+
+// Write writes the entry to the WAL segment.
+// It returns errors.SealedWALErr if the segment is sealed (or its seal failed).
 func (wal *WAL) Write(entry *Entry) error {
+	if wal.sealed {
+		return errors.SealedWALErr
+	}
 	encodedEntry, err := entry.Encode()
 	if err != nil {
 		return err
@@ -45,16 +107,96 @@ func (wal *WAL) Write(entry *Entry) error {
 	return nil
 }
 
+// WriteAll writes all the entries to the WAL segment with a single write, in the order of the entries.
+// It returns errors.SealedWALErr if the segment is sealed (or its seal failed).
+func (wal *WAL) WriteAll(entries []*Entry) error {
+	if wal.sealed {
+		return errors.SealedWALErr
+	}
+	var encodedEntries []byte
+	for _, entry := range entries {
+		encodedEntry, err := entry.Encode()
+		if err != nil {
+			return err
+		}
+		encodedEntries = append(encodedEntries, encodedEntry...)
+	}
+	bytesWritten, err := wal.writableFileHandle.Write(encodedEntries)
+	if err != nil {
+		return err
+	}
+	if bytesWritten < len(encodedEntries) {
+		return fmt.Errorf("could not append %v bytes to the WAL", len(encodedEntries))
+	}
+	wal.currentWritableOffset = wal.currentWritableOffset + uint64(bytesWritten)
+	return nil
+}
+
+// Sync commits the entries written to the WAL segment to the disk.
+func (wal *WAL) Sync() error {
+	return wal.writableFileHandle.Sync()
+}
+
+// Seal writes the Footer with the lastCommitTimestamp at the end of the WAL segment, syncs and closes the segment.
+// No entries can be written to a sealed segment.
+// The segment stops accepting entries (and another Seal) as soon as Seal is attempted, even if Seal fails: the Footer may
+// already be written, and the entries written after the Footer would be lost on recovery. So, a failed Seal is permanent,
+// and all the subsequent writes return errors.SealedWALErr.
+func (wal *WAL) Seal(lastCommitTimestamp uint64) error {
+	if wal.sealed {
+		return errors.SealedWALErr
+	}
+	wal.sealed = true
+	encodedFooter := Footer{lastCommitTimestamp: lastCommitTimestamp}.encode()
+	if _, err := wal.writableFileHandle.Write(encodedFooter); err != nil {
+		return err
+	}
+	wal.currentWritableOffset = wal.currentWritableOffset + uint64(len(encodedFooter))
+	if err := wal.writableFileHandle.Sync(); err != nil {
+		return err
+	}
+	return wal.writableFileHandle.Close()
+}
+
+// Close syncs and closes the WAL segment, without sealing it. The entries of the segment are recovered on the next start.
+func (wal *WAL) Close() error {
+	if err := wal.writableFileHandle.Sync(); err != nil {
+		return err
+	}
+	return wal.writableFileHandle.Close()
+}
+
+// LastCommitTimestamp returns the last commit timestamp recorded in the Footer and true if the segment is sealed,
+// (0, false) otherwise. It is only available for a WAL created using NewReadonlyWAL.
+func (wal *WAL) LastCommitTimestamp() (uint64, bool) {
+	if wal.footer == nil {
+		return 0, false
+	}
+	return wal.footer.lastCommitTimestamp, true
+}
+
 func (wal *WAL) Remove() {
-	err := os.RemoveAll(wal.writableFileHandle.Name())
+	if wal.writableFileHandle != nil {
+		_ = wal.writableFileHandle.Close()
+	}
+	err := os.RemoveAll(wal.filePath)
 	if err != nil {
 		//TODO: Removes println in favor of logging
-		println("error while closing WAL file ", wal.writableFileHandle.Name())
+		println("error while closing WAL file ", wal.filePath)
 	}
 }
 
+// CloseReadonly closes the readable file handle of a WAL that is created using NewReadonlyWAL.
+func (wal *WAL) CloseReadonly() error {
+	return wal.readableFileHandle.Close()
+}
+
+// Iterator returns a WalIterator over all the entries of the segment. The Footer (if any) is not a part of the entries.
 func (wal *WAL) Iterator() *WalIterator {
-	return &WalIterator{reader: NewBufferedReader(wal.readableFileHandle)}
+	return &WalIterator{
+		reader: NewBufferedReader(io.NewSectionReader(wal.readableFileHandle, 0, wal.entriesSize)),
+		size:   wal.entriesSize,
+	}
 }
 
 func (wal WAL) CurrentWritableOffset() uint64 {
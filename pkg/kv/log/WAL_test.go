@@ -2,7 +2,11 @@ package log
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"tinydb/pkg/kv/log/errors"
 )
 
 func TestWalWithSingleEntry(t *testing.T) {
@@ -64,3 +68,193 @@ func TestWalWithMultipleEntries(t *testing.T) {
 		assert.Equal(t, expected[key], string(entry.value))
 	}
 }
+
+func TestWalWithAValueLargerThanTheReadBuffer(t *testing.T) {
+	wal, _ := NewWAL(20, t.TempDir())
+	largeValue := make([]byte, 64*1024)
+	for index := range largeValue {
+		largeValue[index] = byte(index)
+	}
+	_ = wal.Write(NewEntry([]byte("blob"), largeValue))
+	_ = wal.writableFileHandle.Close()
+
+	readOnlyWal, _ := NewReadonlyWAL(20, filepath.Dir(wal.writableFileHandle.Name()))
+	defer func() {
+		_ = readOnlyWal.CloseReadonly()
+	}()
+
+	entry, err := readOnlyWal.Iterator().Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "blob", string(entry.Key()))
+	assert.Equal(t, largeValue, entry.Value())
+}
+
+func TestWalContinuesFromTheExistingOffset(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(30, directory)
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	_ = wal.writableFileHandle.Close()
+
+	reopenedWal, _ := NewWAL(30, directory)
+	defer func() {
+		_ = reopenedWal.writableFileHandle.Close()
+	}()
+	assert.Equal(t, wal.CurrentWritableOffset(), reopenedWal.CurrentWritableOffset())
+}
+
+func TestSealsTheWalWithTheLastCommitTimestamp(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(40, directory)
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	_ = wal.Write(NewEntry([]byte("type"), []byte("relational")))
+	assert.Nil(t, wal.Seal(12))
+
+	readOnlyWal, _ := NewReadonlyWAL(40, directory)
+	defer func() {
+		_ = readOnlyWal.CloseReadonly()
+	}()
+
+	lastCommitTimestamp, sealed := readOnlyWal.LastCommitTimestamp()
+	assert.True(t, sealed)
+	assert.Equal(t, uint64(12), lastCommitTimestamp)
+
+	iterator := readOnlyWal.Iterator()
+	for _, key := range []string{"db", "type"} {
+		entry, err := iterator.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, key, string(entry.Key()))
+	}
+	_, err := iterator.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestAnUnsealedWalDoesNotHaveTheLastCommitTimestamp(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(50, directory)
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	_ = wal.writableFileHandle.Close()
+
+	readOnlyWal, _ := NewReadonlyWAL(50, directory)
+	defer func() {
+		_ = readOnlyWal.CloseReadonly()
+	}()
+
+	_, sealed := readOnlyWal.LastCommitTimestamp()
+	assert.False(t, sealed)
+}
+
+func TestSyncsTheWal(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(60, directory)
+	defer wal.Remove()
+
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	assert.Nil(t, wal.Sync())
+}
+
+func TestWritesAllTheEntriesToTheWal(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(70, directory)
+	defer wal.Remove()
+
+	err := wal.WriteAll([]*Entry{
+		NewEntry([]byte("db"), []byte("tinyDB")),
+		NewEntry([]byte("type"), []byte("relational")),
+	})
+	assert.Nil(t, err)
+
+	readOnlyWal, _ := NewReadonlyWAL(70, directory)
+	defer func() {
+		_ = readOnlyWal.CloseReadonly()
+	}()
+
+	iterator := readOnlyWal.Iterator()
+	for _, key := range []string{"db", "type"} {
+		entry, err := iterator.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, key, string(entry.Key()))
+	}
+	assert.Equal(t, readOnlyWal.entriesSize, int64(wal.CurrentWritableOffset()))
+}
+
+func writeEntriesAndCorrupt(t *testing.T, fileId uint64, directory string, corrupt func(file *os.File, firstEntrySize int64)) {
+	wal, _ := NewWAL(fileId, directory)
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	firstEntrySize := int64(wal.CurrentWritableOffset())
+	_ = wal.Write(NewEntry([]byte("type"), []byte("relational")))
+
+	_ = wal.writableFileHandle.Close()
+
+	file, _ := os.OpenFile(FilePath(fileId, directory), os.O_RDWR, 0644)
+	corrupt(file, firstEntrySize)
+	_ = file.Close()
+}
+
+func assertIteratorStopsAfterTheFirstEntry(t *testing.T, fileId uint64, directory string, expectedErr error) {
+	readOnlyWal, _ := NewReadonlyWAL(fileId, directory)
+	defer func() {
+		_ = readOnlyWal.CloseReadonly()
+	}()
+
+	iterator := readOnlyWal.Iterator()
+	entry, err := iterator.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "db", string(entry.Key()))
+	validOffset := iterator.ValidOffset()
+
+	_, err = iterator.Next()
+	assert.Equal(t, expectedErr, err)
+	_, err = iterator.Next()
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, validOffset, iterator.ValidOffset())
+}
+
+func TestIteratorStopsAtACorruptEntry(t *testing.T) {
+	directory := t.TempDir()
+	writeEntriesAndCorrupt(t, 80, directory, func(file *os.File, firstEntrySize int64) {
+		_, _ = file.WriteAt([]byte{'X'}, firstEntrySize+int64(HeaderLength))
+	})
+	assertIteratorStopsAfterTheFirstEntry(t, 80, directory, errors.CorruptEntryErr)
+}
+
+func TestIteratorStopsAtATruncatedEntry(t *testing.T) {
+	directory := t.TempDir()
+	writeEntriesAndCorrupt(t, 81, directory, func(file *os.File, firstEntrySize int64) {
+		_ = file.Truncate(firstEntrySize + int64(HeaderLength) + 2)
+	})
+	assertIteratorStopsAfterTheFirstEntry(t, 81, directory, errors.TruncatedEntryErr)
+}
+
+func TestIteratorStopsAtAnEntryWithAGarbageLength(t *testing.T) {
+	directory := t.TempDir()
+	writeEntriesAndCorrupt(t, 82, directory, func(file *os.File, firstEntrySize int64) {
+		_, _ = file.WriteAt([]byte{0xFF, 0xFF, 0xFF, 0x7F}, firstEntrySize+int64(ChecksumLength))
+	})
+	assertIteratorStopsAfterTheFirstEntry(t, 82, directory, errors.TruncatedEntryErr)
+}
+
+func TestTruncatesTheWal(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(83, directory)
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	_ = wal.writableFileHandle.Close()
+
+	assert.Nil(t, Truncate(83, directory, 5))
+
+	stat, _ := os.Stat(FilePath(83, directory))
+	assert.Equal(t, int64(5), stat.Size())
+}
+
+func TestDoesNotWriteToAWalWhoseSealFails(t *testing.T) {
+	directory := t.TempDir()
+	wal, _ := NewWAL(80, directory)
+	defer wal.Remove()
+
+	_ = wal.Write(NewEntry([]byte("db"), []byte("tinyDB")))
+	_ = wal.writableFileHandle.Close()
+	assert.NotNil(t, wal.Seal(12))
+
+	assert.Equal(t, errors.SealedWALErr, wal.Write(NewEntry([]byte("type"), []byte("relational"))))
+	assert.Equal(t, errors.SealedWALErr, wal.WriteAll([]*Entry{NewEntry([]byte("type"), []byte("relational"))}))
+	assert.Equal(t, errors.SealedWALErr, wal.Seal(12))
+}
@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var CorruptEntryErr = errors.New("wal entry is corrupt, its checksum does not match")
+var TruncatedEntryErr = errors.New("wal entry is truncated, it extends beyond the end of the segment")
+var SealedWALErr = errors.New("wal segment is sealed (or its seal failed), no entries can be written to it")
@@ -1,15 +1,28 @@
 package mvcc
 
 import (
+	"io"
+	"sync/atomic"
 	"tinydb/pkg/kv/log"
+	"tinydb/pkg/kv/log/errors"
 	"tinydb/pkg/kv/option"
 )
 
+// VersionedKeyValue is a pair of VersionedKey and Value. It is used to write multiple key/value pairs together.
+type VersionedKeyValue struct {
+	Key   VersionedKey
+	Value Value
+}
+
 // MemTable is an in-memory structure built on top of SkipList.
+// MemTable also tracks the maxVersion, which is the largest commitTimestamp of all the keys that are written to it.
 type MemTable struct {
-	skiplist *Skiplist
-	wal      *log.WAL
-	options  *option.Options
+	fileId     uint64
+	skiplist   *Skiplist
+	wal        *log.WAL
+	maxVersion atomic.Uint64
+	sealed     bool
+	options    *option.Options
 }
 
 // NewMemTable creates a new instance of MemTable.
@@ -20,17 +33,100 @@ func NewMemTable(fileId uint64, options *option.Options) (*MemTable, error) {
 		return nil, err
 	}
 	return &MemTable{
+		fileId:   fileId,
 		skiplist: newSkiplist(),
 		wal:      wal,
 		options:  options,
 	}, nil
 }
 
+// RecoverMemTable creates a new instance of MemTable from an existing WAL identified by the fileId.
+// All the entries of the WAL are replayed, in the order they were written, into the SkipList.
+// Replay stops at the first corrupt or truncated entry (a torn write), and the WAL is truncated back to the last valid entry.
+// If the WAL is sealed, the recovered memtable is sealed as well and its maxVersion is the last commit timestamp recorded
+// in the footer of the WAL (a sealed WAL that is truncated is sealed again). Otherwise, the WAL is re-opened for writing,
+// so that the recovered memtable can continue to accept writes (as the active memtable).
+func RecoverMemTable(fileId uint64, options *option.Options) (*MemTable, error) {
+	readonlyWAL, err := log.NewReadonlyWAL(fileId, options.DbDirectory)
+	if err != nil {
+		return nil, err
+	}
+	skiplist, maxVersion, torn := newSkiplist(), uint64(0), false
+	iterator := readonlyWAL.Iterator()
+	for {
+		entry, err := iterator.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == errors.CorruptEntryErr || err == errors.TruncatedEntryErr {
+			torn = true
+			break
+		}
+		if err != nil {
+			_ = readonlyWAL.CloseReadonly()
+			return nil, err
+		}
+		key, value := new(VersionedKey), new(Value)
+		key.DecodeFrom(entry.Key())
+		value.DecodeFrom(entry.Value())
+		skiplist.putOrUpdate(*key, *value)
+		if key.Version > maxVersion {
+			maxVersion = key.Version
+		}
+	}
+	if err := readonlyWAL.CloseReadonly(); err != nil {
+		return nil, err
+	}
+	if torn {
+		if err := log.Truncate(fileId, options.DbDirectory, iterator.ValidOffset()); err != nil {
+			return nil, err
+		}
+	}
+
+	memTable := &MemTable{fileId: fileId, skiplist: skiplist, options: options}
+	lastCommitTimestamp, sealed := readonlyWAL.LastCommitTimestamp()
+	if sealed && !torn {
+		memTable.wal, memTable.sealed = readonlyWAL, true
+		memTable.maxVersion.Store(lastCommitTimestamp)
+		return memTable, nil
+	}
+	wal, err := log.NewWAL(fileId, options.DbDirectory)
+	if err != nil {
+		return nil, err
+	}
+	memTable.wal = wal
+	memTable.maxVersion.Store(maxVersion)
+	if sealed {
+		memTable.maxVersion.Store(lastCommitTimestamp)
+		if err := memTable.Seal(); err != nil {
+			return nil, err
+		}
+	}
+	return memTable, nil
+}
+
 // PutOrUpdate puts or updates the key and the value pair in the associated WAL and the SkipList.
 func (memTable *MemTable) PutOrUpdate(key VersionedKey, value Value) error {
 	return memTable.write(key, value)
 }
 
+// PutOrUpdateAll puts or updates all the key/value pairs in the associated WAL (with a single write) and the SkipList.
+// The key/value pairs are added to the SkipList in the order they are passed, only after all of them are written to the WAL.
+func (memTable *MemTable) PutOrUpdateAll(pairs []VersionedKeyValue) error {
+	entries := make([]*log.Entry, 0, len(pairs))
+	for _, pair := range pairs {
+		entries = append(entries, log.NewEntry(pair.Key.Encode(), pair.Value.Encode()))
+	}
+	if err := memTable.wal.WriteAll(entries); err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		memTable.skiplist.putOrUpdate(pair.Key, pair.Value)
+		memTable.updateMaxVersion(pair.Key.Version)
+	}
+	return nil
+}
+
 // Delete deletes the key.
 // Deletion is not a physical deletion.
 // Deletion involves: Creating a new Entry with a NewDeletedValue and appending the entry in the WAL.
@@ -45,6 +141,66 @@ func (memTable *MemTable) Get(key VersionedKey) (ValueWithVersion, bool) {
 	return memTable.skiplist.get(key)
 }
 
+// GetIncludingDeleted returns a pair of (ValueWithVersion, bool) for the incoming key.
+// Unlike Get, it returns (ValueWithVersion, true) even if the latest version of the key is deleted. This allows the
+// callers that search across multiple memtables (and SSTables) to stop at the deleted version.
+func (memTable *MemTable) GetIncludingDeleted(key VersionedKey) (ValueWithVersion, bool) {
+	return memTable.skiplist.getIncludingDeleted(key)
+}
+
+// ForEach invokes the callback for all the key/value pairs in the increasing order of the VersionedKey.
+// ForEach is used to flush the (immutable) memtable to an SSTable.
+func (memTable *MemTable) ForEach(callback func(key VersionedKey, value Value)) {
+	iterator := memTable.NewIterator()
+	for iterator.SeekToFirst(); iterator.IsValid(); iterator.Next() {
+		callback(iterator.Key(), iterator.Value())
+	}
+}
+
+// NewIterator returns an Iterator over all the key/value pairs of the memtable, including the deleted values.
+func (memTable *MemTable) NewIterator() *Iterator {
+	return memTable.skiplist.iterator()
+}
+
+// Sync syncs the WAL of the memtable.
+func (memTable *MemTable) Sync() error {
+	return memTable.wal.Sync()
+}
+
+// Seal seals the WAL of the memtable, recording the maxVersion in the footer of the WAL.
+// A memtable is sealed when it becomes full (and immutable). No key/value pair can be written to a sealed memtable.
+func (memTable *MemTable) Seal() error {
+	if err := memTable.wal.Seal(memTable.MaxVersion()); err != nil {
+		return err
+	}
+	memTable.sealed = true
+	return nil
+}
+
+// Close syncs and closes the WAL of the memtable, if the memtable is not sealed (a sealed WAL is already synced and closed).
+// No key/value pair can be written to a closed memtable, the memtable is recovered from its WAL on the next start.
+func (memTable *MemTable) Close() error {
+	if memTable.sealed {
+		return nil
+	}
+	return memTable.wal.Close()
+}
+
+// IsSealed returns true if the memtable is sealed, false otherwise.
+func (memTable *MemTable) IsSealed() bool {
+	return memTable.sealed
+}
+
+// MaxVersion returns the largest commitTimestamp of all the keys that are written to the memtable.
+func (memTable *MemTable) MaxVersion() uint64 {
+	return memTable.maxVersion.Load()
+}
+
+// FileId returns the id of the WAL file of the memtable.
+func (memTable *MemTable) FileId() uint64 {
+	return memTable.fileId
+}
+
 // RemoveWAL removes the WAL file.
 func (memTable *MemTable) RemoveWAL() {
 	memTable.wal.Remove()
@@ -57,9 +213,19 @@ func (memTable *MemTable) write(key VersionedKey, value Value) error {
 		return err
 	}
 	memTable.skiplist.putOrUpdate(key, value)
+	memTable.updateMaxVersion(key.Version)
 	return nil
 }
 
+// updateMaxVersion sets the maxVersion to the version, if the version is greater than the maxVersion.
+func (memTable *MemTable) updateMaxVersion(version uint64) {
+	for maxVersion := memTable.maxVersion.Load(); version > maxVersion; maxVersion = memTable.maxVersion.Load() {
+		if memTable.maxVersion.CompareAndSwap(maxVersion, version) {
+			break
+		}
+	}
+}
+
 // IsFull returns true of the size of the memtable is greater or equal to the maximum size of the MemTable.
 // IsFull will check the size of the Skiplist and the CurrentWritableOffset of WAL to check if the MemTable is full.
 func (memTable *MemTable) IsFull() bool {
@@ -3,8 +3,10 @@ package mvcc
 import (
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"os"
 	"sync"
 	"testing"
+	"tinydb/pkg/kv/log"
 	"tinydb/pkg/kv/option"
 )
 
@@ -167,7 +169,7 @@ func TestMemtableIsFullGivenWALIsFull(t *testing.T) {
 }
 
 func TestMemtableIsNotFull(t *testing.T) {
-	memTable, _ := NewMemTable(RandomWALFileId(), option.DefaultOptions().SetMemtableSizeInBytes(32).SetDbDirectory("."))
+	memTable, _ := NewMemTable(RandomWALFileId(), option.DefaultOptions().SetMemtableSizeInBytes(40).SetDbDirectory("."))
 	defer memTable.RemoveWAL()
 
 	key := NewVersionedKey([]byte("HDD"), 1)
@@ -177,3 +179,103 @@ func TestMemtableIsNotFull(t *testing.T) {
 	assert.Equal(t, uint64(21), memTable.skiplist.size)
 	assert.Equal(t, false, memTable.IsFull())
 }
+
+func TestRecoversAMemTableFromTheWAL(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	memTable, _ := NewMemTable(1, options)
+	_ = memTable.PutOrUpdate(NewVersionedKey([]byte("HDD"), 1), NewValue([]byte("Hard disk")))
+	_ = memTable.Delete(NewVersionedKey([]byte("HDD"), 2))
+	_ = memTable.PutOrUpdate(NewVersionedKey([]byte("SSD"), 1), NewValue([]byte("Solid state")))
+
+	recovered, err := RecoverMemTable(1, options)
+	assert.Nil(t, err)
+	defer recovered.RemoveWAL()
+
+	valueWithVersion, ok := recovered.Get(NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+
+	_, ok = recovered.Get(NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, false, ok)
+
+	valueWithVersion, ok = recovered.Get(NewVersionedKey([]byte("SSD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Solid state"), valueWithVersion.ValueSlice())
+	assert.Equal(t, memTable.skiplist.size, recovered.skiplist.size)
+}
+
+func TestTracksTheMaxVersionOfTheMemTable(t *testing.T) {
+	memTable, _ := NewMemTable(1, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer memTable.RemoveWAL()
+
+	_ = memTable.PutOrUpdate(NewVersionedKey([]byte("HDD"), 5), NewValue([]byte("Hard disk")))
+	_ = memTable.PutOrUpdate(NewVersionedKey([]byte("SSD"), 3), NewValue([]byte("Solid state")))
+
+	assert.Equal(t, uint64(5), memTable.MaxVersion())
+}
+
+func TestRecoversASealedMemTableFromTheWAL(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	memTable, _ := NewMemTable(1, options)
+	_ = memTable.PutOrUpdate(NewVersionedKey([]byte("HDD"), 7), NewValue([]byte("Hard disk")))
+	assert.Nil(t, memTable.Seal())
+
+	recovered, err := RecoverMemTable(1, options)
+	assert.Nil(t, err)
+	defer recovered.RemoveWAL()
+
+	assert.True(t, recovered.IsSealed())
+	assert.Equal(t, uint64(7), recovered.MaxVersion())
+
+	valueWithVersion, ok := recovered.Get(NewVersionedKey([]byte("HDD"), 7))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+}
+
+func TestPutsAllTheKeysInTheMemTable(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	memTable, _ := NewMemTable(1, options)
+
+	err := memTable.PutOrUpdateAll([]VersionedKeyValue{
+		{Key: NewVersionedKey([]byte("HDD"), 1), Value: NewValue([]byte("Hard disk"))},
+		{Key: NewVersionedKey([]byte("SSD"), 2), Value: NewValue([]byte("Solid state"))},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), memTable.MaxVersion())
+
+	valueWithVersion, ok := memTable.Get(NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Solid state"), valueWithVersion.ValueSlice())
+
+	_ = memTable.Seal()
+	recovered, _ := RecoverMemTable(1, options)
+	defer recovered.RemoveWAL()
+
+	valueWithVersion, ok = recovered.Get(NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+}
+
+func TestRecoversAMemTableFromATornWALAndTruncatesIt(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	wal, _ := log.NewWAL(1, options.DbDirectory)
+	_ = wal.Write(log.NewEntry(NewVersionedKey([]byte("HDD"), 1).Encode(), NewValue([]byte("Hard disk")).Encode()))
+	validSize := int64(wal.CurrentWritableOffset())
+	_ = wal.Write(log.NewEntry(NewVersionedKey([]byte("SSD"), 2).Encode(), NewValue([]byte("Solid state")).Encode()))
+	_ = log.Truncate(1, options.DbDirectory, validSize+5)
+
+	recovered, err := RecoverMemTable(1, options)
+	assert.Nil(t, err)
+	defer recovered.RemoveWAL()
+
+	_, ok := recovered.Get(NewVersionedKey([]byte("SSD"), 2))
+	assert.Equal(t, false, ok)
+
+	valueWithVersion, ok := recovered.Get(NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+	assert.Equal(t, uint64(1), recovered.MaxVersion())
+
+	stat, _ := os.Stat(log.FilePath(1, options.DbDirectory))
+	assert.Equal(t, validSize, stat.Size())
+}
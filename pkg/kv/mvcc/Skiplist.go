@@ -40,6 +40,13 @@ func (skiplist *Skiplist) get(key VersionedKey) (ValueWithVersion, bool) {
 	return skiplist.head.get(key)
 }
 
+// getIncludingDeleted returns a pair of (ValueWithVersion, bool) for the incoming key, including the deleted value.
+func (skiplist *Skiplist) getIncludingDeleted(key VersionedKey) (ValueWithVersion, bool) {
+	skiplist.lock.RLock()
+	defer skiplist.lock.RUnlock()
+	return skiplist.head.getIncludingDeleted(key)
+}
+
 // iterator returns an Iterator that allows forward movement in the Skiplist
 func (skiplist *Skiplist) iterator() *Iterator {
 	return &Iterator{
@@ -102,76 +109,90 @@ func (node *SkiplistNode) putOrUpdate(key VersionedKey, value Value, levelGenera
 // 2. the key prefixes match.
 // KeyPrefix is the actual key or the byte slice.
 func (node *SkiplistNode) get(key VersionedKey) (ValueWithVersion, bool) {
-	node, ok := node.matchingNode(key)
-	if ok && !node.value.IsDeleted() {
-		return NewValueWithVersion(node.value, node.key.Version), true
+	valueWithVersion, ok := node.getIncludingDeleted(key)
+	if ok && !valueWithVersion.IsDeleted() {
+		return valueWithVersion, true
+	}
+	return EmptyValueWithZeroVersion(), false
+}
+
+// getIncludingDeleted behaves like get, but it also returns the value if the matching node is deleted.
+func (node *SkiplistNode) getIncludingDeleted(key VersionedKey) (ValueWithVersion, bool) {
+	matchingNode, ok := node.matchingNode(key)
+	if ok {
+		return NewValueWithVersion(matchingNode.value, matchingNode.key.Version), true
 	}
 	return EmptyValueWithZeroVersion(), false
 }
 
+// matchingNode returns the node with the largest VersionedKey that is less than or equal to the incoming key, provided
+// the key prefixes match. A node with a Version greater than the Version of the incoming key is never returned, because
+// that Version is not visible to the reader.
 func (node *SkiplistNode) matchingNode(key VersionedKey) (*SkiplistNode, bool) {
 	current := node
-	lastNodeWithTheKey := current
 	for level := len(node.forwards) - 1; level >= 0; level-- {
-		for current.forwards[level] != nil && current.forwards[level].key.Compare(key) < 0 {
+		for current.forwards[level] != nil && current.forwards[level].key.Compare(key) <= 0 {
 			current = current.forwards[level]
-			lastNodeWithTheKey = current
 		}
 	}
-	if current != nil {
-		current = current.forwards[0]
-	}
-	if current != nil && current.key.matchesKeyPrefix(key.getKey()) {
+	if current != node && current.key.matchesKeyPrefix(key.getKey()) {
 		return current, true
 	}
-	if lastNodeWithTheKey != nil && lastNodeWithTheKey.key.matchesKeyPrefix(key.getKey()) {
-		return lastNodeWithTheKey, true
-	}
 	return nil, false
 }
 
-// Iterator allows forward movement in the Skiplist
+// Iterator allows forward movement in the Skiplist.
+// A new Iterator is positioned at the sentinel (head) node, Seek or SeekToFirst must be called before reading the key/value.
 type Iterator struct {
 	skiplist *Skiplist
 	node     *SkiplistNode
 }
 
-// seek to a node such that node.key >= key
-func (iterator *Iterator) seek(key VersionedKey) {
+// Seek positions the Iterator at the first node such that node.key >= key. The search always starts from the head.
+func (iterator *Iterator) Seek(key VersionedKey) {
 	iterator.skiplist.lock.RLock()
 	defer iterator.skiplist.lock.RUnlock()
 
-	current := iterator.node
-	for level := len(iterator.node.forwards) - 1; level >= 0; level-- {
+	head := iterator.skiplist.head
+	current := head
+	for level := len(head.forwards) - 1; level >= 0; level-- {
 		for current.forwards[level] != nil && current.forwards[level].key.Compare(key) <= 0 {
 			current = current.forwards[level]
 		}
 	}
-	if current.key.Compare(key) < 0 {
+	if current == head || current.key.Compare(key) < 0 {
 		current = current.forwards[0]
 	}
 	iterator.node = current
 }
 
-// isValid returns true if the current iterator node is not nil, false otherwise
-func (iterator *Iterator) isValid() bool {
+// SeekToFirst positions the Iterator at the first node after the head.
+func (iterator *Iterator) SeekToFirst() {
+	iterator.skiplist.lock.RLock()
+	defer iterator.skiplist.lock.RUnlock()
+
+	iterator.node = iterator.skiplist.head.forwards[0]
+}
+
+// IsValid returns true if the current iterator node is not nil, false otherwise
+func (iterator *Iterator) IsValid() bool {
 	return iterator.node != nil
 }
 
-// key returns the key present in the current node pointed to by the Iterator
-func (iterator *Iterator) key() VersionedKey {
+// Key returns the key present in the current node pointed to by the Iterator
+func (iterator *Iterator) Key() VersionedKey {
 	return iterator.node.key
 }
 
-// value returns the ValueWithVersion present in the current node pointed to by the Iterator
-func (iterator *Iterator) value() ValueWithVersion {
-	return NewValueWithVersion(iterator.node.value, iterator.key().Version)
+// Value returns the Value present in the current node pointed to by the Iterator. The version is a part of the Key.
+func (iterator *Iterator) Value() Value {
+	return iterator.node.value
 }
 
-// next moves the iterator forward. It is ESSENTIAL to call isValid() before calling next.
-// No nil check is done on the iterator node. It is the responsibility of the callee to ensure next is only called if the
+// Next moves the iterator forward. It is ESSENTIAL to call IsValid() before calling Next.
+// No nil check is done on the iterator node. It is the responsibility of the callee to ensure Next is only called if the
 // Iterator is valid
-func (iterator *Iterator) next() {
+func (iterator *Iterator) Next() {
 	iterator.skiplist.lock.RLock()
 	defer iterator.skiplist.lock.RUnlock()
 
@@ -95,12 +95,12 @@ func TestIteratorSeekWithMatchingKey(t *testing.T) {
 	skiplist.putOrUpdate(NewVersionedKey([]byte("SSD"), 2), NewValue([]byte("Solid state")))
 
 	iterator := skiplist.iterator()
-	iterator.seek(NewVersionedKey([]byte("SSD"), 2))
+	iterator.Seek(NewVersionedKey([]byte("SSD"), 2))
 
-	assert.True(t, iterator.isValid())
-	assert.Equal(t, uint64(2), iterator.value().Version)
-	assert.Equal(t, "SSD", iterator.key().AsString())
-	assert.Equal(t, "Solid state", string(iterator.value().ValueSlice()))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, uint64(2), iterator.Key().Version)
+	assert.Equal(t, "SSD", iterator.Key().AsString())
+	assert.Equal(t, "Solid state", string(iterator.Value().ValueSlice()))
 }
 
 func TestIteratorSeekWithKeyGreaterThanTheExistingKey(t *testing.T) {
@@ -110,11 +110,11 @@ func TestIteratorSeekWithKeyGreaterThanTheExistingKey(t *testing.T) {
 	skiplist.putOrUpdate(NewVersionedKey([]byte("SSD"), 2), NewValue([]byte("Solid state")))
 
 	iterator := skiplist.iterator()
-	iterator.seek(NewVersionedKey([]byte("SSD"), 1))
+	iterator.Seek(NewVersionedKey([]byte("SSD"), 1))
 
-	assert.True(t, iterator.isValid())
-	assert.Equal(t, "SSD", iterator.key().AsString())
-	assert.Equal(t, "Solid state", string(iterator.value().ValueSlice()))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, "SSD", iterator.Key().AsString())
+	assert.Equal(t, "Solid state", string(iterator.Value().ValueSlice()))
 }
 
 func TestIteratorSeekWithKeyDifferentThanKeyPrefix(t *testing.T) {
@@ -125,12 +125,12 @@ func TestIteratorSeekWithKeyDifferentThanKeyPrefix(t *testing.T) {
 	skiplist.putOrUpdate(NewVersionedKey([]byte("SSD"), 2), NewValue([]byte("Solid state")))
 
 	iterator := skiplist.iterator()
-	iterator.seek(NewVersionedKey([]byte("DB"), 2))
+	iterator.Seek(NewVersionedKey([]byte("DB"), 2))
 
-	assert.True(t, iterator.isValid())
-	assert.Equal(t, uint64(1), iterator.value().Version)
-	assert.Equal(t, "HDD", iterator.key().AsString())
-	assert.Equal(t, "Hard disk", string(iterator.value().ValueSlice()))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, uint64(1), iterator.Key().Version)
+	assert.Equal(t, "HDD", iterator.Key().AsString())
+	assert.Equal(t, "Hard disk", string(iterator.Value().ValueSlice()))
 }
 
 func TestIteratorNext(t *testing.T) {
@@ -141,19 +141,19 @@ func TestIteratorNext(t *testing.T) {
 	skiplist.putOrUpdate(NewVersionedKey([]byte("SSD"), 2), NewValue([]byte("Solid state")))
 
 	iterator := skiplist.iterator()
-	iterator.seek(NewVersionedKey([]byte("DB"), 2))
+	iterator.Seek(NewVersionedKey([]byte("DB"), 2))
 
-	assert.True(t, iterator.isValid())
-	assert.Equal(t, "HDD", iterator.key().AsString())
-	assert.Equal(t, "Hard disk", string(iterator.value().ValueSlice()))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, "HDD", iterator.Key().AsString())
+	assert.Equal(t, "Hard disk", string(iterator.Value().ValueSlice()))
 
-	iterator.next()
-	assert.True(t, iterator.isValid())
-	assert.Equal(t, "SSD", iterator.key().AsString())
-	assert.Equal(t, "Solid state", string(iterator.value().ValueSlice()))
+	iterator.Next()
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, "SSD", iterator.Key().AsString())
+	assert.Equal(t, "Solid state", string(iterator.Value().ValueSlice()))
 
-	iterator.next()
-	assert.False(t, iterator.isValid())
+	iterator.Next()
+	assert.False(t, iterator.IsValid())
 }
 
 func TestPutsAKeyValueAndGetsTheSize(t *testing.T) {
@@ -175,3 +175,32 @@ func TestPutsADeletedKeyValueAndGetsTheSize(t *testing.T) {
 	skiplist.putOrUpdate(key, value)
 	assert.Equal(t, uint64(12), skiplist.size)
 }
+
+func TestGetsTheValueOfAKeyWithAnOlderVersionGivenANewerVersionExists(t *testing.T) {
+	skiplist := newSkiplist()
+	skiplist.putOrUpdate(NewVersionedKey([]byte("HDD"), 1), NewValue([]byte("Hard disk")))
+	skiplist.putOrUpdate(NewVersionedKey([]byte("HDD"), 5), NewValue([]byte("Hard disk drive")))
+
+	valueWithVersion, ok := skiplist.get(NewVersionedKey([]byte("HDD"), 3))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(1), valueWithVersion.Version)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+}
+
+func TestGetsTheValueOfAKeyWithAVersionOlderThanAllTheVersions(t *testing.T) {
+	skiplist := newSkiplist()
+	skiplist.putOrUpdate(NewVersionedKey([]byte("HDD"), 2), NewValue([]byte("Hard disk")))
+
+	_, ok := skiplist.get(NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, false, ok)
+}
+
+func TestGetsADeletedKeyIncludingDeleted(t *testing.T) {
+	skiplist := newSkiplist()
+	skiplist.putOrUpdate(NewVersionedKey([]byte("HDD"), 1), NewValue([]byte("Hard disk")))
+	skiplist.putOrUpdate(NewVersionedKey([]byte("HDD"), 2), NewDeletedValue())
+
+	valueWithVersion, ok := skiplist.getIncludingDeleted(NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, valueWithVersion.IsDeleted())
+}
@@ -2,14 +2,22 @@ package mvcc
 
 import "unsafe"
 
-const deletedFlagSize = int(unsafe.Sizeof(byte(0)))
+const flagsSize = int(unsafe.Sizeof(byte(0)))
+
+const (
+	// deletedFlag marks a Value as a tombstone.
+	deletedFlag = byte(0x01)
+	// valuePointerFlag marks a Value that holds an encoded pointer to the actual value in the value log, instead of the value.
+	valuePointerFlag = byte(0x02)
+)
 
 var nilValue []byte
 
 // Value wraps a []byte which acts as a value in the MemTable.
+// flags is a combination of deletedFlag and valuePointerFlag.
 type Value struct {
-	value   []byte
-	deleted byte
+	value []byte
+	flags byte
 }
 
 // ValueWithVersion wraps the Value and its Version. It is returned from Skiplist as a part of Get method and also from the Iterator.
@@ -34,16 +42,25 @@ func EmptyValueWithZeroVersion() ValueWithVersion {
 // NewValue creates a new instance of the Value.
 func NewValue(value []byte) Value {
 	return Value{
-		value:   value,
-		deleted: byte(0),
+		value: value,
+		flags: byte(0),
 	}
 }
 
 // NewDeletedValue creates a new instance of the Value with deleted flag.
 func NewDeletedValue() Value {
 	return Value{
-		value:   nilValue,
-		deleted: byte(1),
+		value: nilValue,
+		flags: deletedFlag,
+	}
+}
+
+// NewValuePointer creates a new instance of the Value that holds the encoded pointer to the actual value, which is
+// stored in the value log. The pointer is opaque to mvcc, it is resolved by the kv.Workspace.
+func NewValuePointer(encodedPointer []byte) Value {
+	return Value{
+		value: encodedPointer,
+		flags: valuePointerFlag,
 	}
 }
 
@@ -59,26 +76,32 @@ func (value Value) ValueSlice() []byte {
 
 // IsDeleted returns true if the value is deleted, false otherwise
 func (value Value) IsDeleted() bool {
-	return value.deleted&0x01 == 0x01
+	return value.flags&deletedFlag == deletedFlag
+}
+
+// IsValuePointer returns true if the Value holds an encoded pointer to the actual value in the value log (refer to
+// NewValuePointer), false otherwise. ValueSlice returns the encoded pointer for such a Value.
+func (value Value) IsValuePointer() bool {
+	return value.flags&valuePointerFlag == valuePointerFlag
 }
 
 // Encode the value to a byte slice.
-// Encoding scheme: [<Value>|<1 byte for the deleted flag>] in a byte slice.
+// Encoding scheme: [<1 byte for the flags>|<Value>] in a byte slice.
 func (value Value) Encode() []byte {
 	encoded := make([]byte, len(value.ValueSlice())+1)
-	copy(encoded[:1], []byte{value.deleted})
+	copy(encoded[:1], []byte{value.flags})
 	copy(encoded[1:], value.value)
 
 	return encoded
 }
 
-// DecodeFrom sets the deleted and value from the byte slice
+// DecodeFrom sets the flags and value from the byte slice
 func (value *Value) DecodeFrom(part []byte) {
-	value.deleted = part[0]
+	value.flags = part[0]
 	value.value = part[1:]
 }
 
 // size returns the total size of a single Value
 func (value Value) size() uint64 {
-	return uint64(len(value.value) + deletedFlagSize)
+	return uint64(len(value.value) + flagsSize)
 }
@@ -48,3 +48,15 @@ func TestDeletedValueSize(t *testing.T) {
 	value := NewDeletedValue()
 	assert.Equal(t, uint64(1), value.size())
 }
+
+func TestValuePointerEncodedValue(t *testing.T) {
+	value := NewValuePointer([]byte("pointer"))
+	encoded := value.Encode()
+
+	decodedValue := new(Value)
+	decodedValue.DecodeFrom(encoded)
+
+	assert.Equal(t, true, decodedValue.IsValuePointer())
+	assert.Equal(t, false, decodedValue.IsDeleted())
+	assert.Equal(t, "pointer", string(decodedValue.ValueSlice()))
+}
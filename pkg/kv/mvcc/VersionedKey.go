@@ -32,6 +32,11 @@ func (versionedKey VersionedKey) getKey() []byte {
 	return versionedKey.key
 }
 
+// KeySlice returns the key part of the VersionedKey.
+func (versionedKey VersionedKey) KeySlice() []byte {
+	return versionedKey.key
+}
+
 // getVersion returns the Version from the VersionedKey
 func (versionedKey VersionedKey) getVersion() uint64 {
 	return versionedKey.Version
@@ -1,15 +1,95 @@
 package option
 
+import "time"
+
+// WALSyncPolicy defines when the WAL is synced (fsync) to the disk.
+type WALSyncPolicy int
+
+const (
+	// WALSyncNever never syncs the WAL explicitly, the operating system decides when the writes reach the disk.
+	// A WAL segment is still synced when it is sealed.
+	WALSyncNever WALSyncPolicy = iota
+	// WALSyncPerBatch syncs the WAL after every batch (transaction) is applied, before the commit is acknowledged.
+	// Batches that are committed together (as a group) share a single sync.
+	WALSyncPerBatch
+	// WALSyncOnInterval syncs the WAL once every WALSyncInterval.
+	WALSyncOnInterval
+)
+
+// IsolationLevel defines the conflicts that are checked when a txn.ReadWriteTransaction commits.
+type IsolationLevel int
+
+const (
+	// SerializableSnapshotIsolation rejects a transaction if a concurrent transaction that committed after its beginTimestamp
+	// wrote a key that the transaction writes, a key that the transaction read, or a key inside a range that the transaction scanned.
+	SerializableSnapshotIsolation IsolationLevel = iota
+	// SnapshotIsolation rejects a transaction only if a concurrent transaction that committed after its beginTimestamp wrote
+	// a key that the transaction writes (first committer wins). It allows write skew.
+	SnapshotIsolation
+)
+
+// CompressionType defines how the data blocks of an SSTable are compressed (refer to sstable.Codec).
+// The CompressionType is stored with every data block, so it can change across the runs.
+type CompressionType byte
+
+const (
+	// NoCompression stores the data blocks raw.
+	NoCompression CompressionType = iota
+	// FlateCompression compresses the data blocks with compress/flate. A data block that does not compress well is stored raw.
+	FlateCompression
+)
+
 type Options struct {
 	DbDirectory             string
 	MemtableSizeInBytes     uint64
 	SSTableBlockSizeInBytes uint32
+	SSTableSizeInBytes      uint64
+	MaxLevels               int
+	Level0MaxTables         int
+	Level1MaxSizeInBytes    uint64
+	LevelSizeMultiplier     uint64
+	BloomFilterBitsPerKey   int
+	WALSyncPolicy           WALSyncPolicy
+	WALSyncInterval         time.Duration
+	IsolationLevel          IsolationLevel
+	MaxBatchEntries         int
+	MaxBatchSizeInBytes     uint64
+	RejectDuplicateKeys     bool
+	TransactionLeakDeadline time.Duration
+	MaxConflictRetries      int
+	ConflictRetryBackoff    time.Duration
+	BlockCacheSizeInBytes   uint64
+	PinLevel0IndexAndFilter bool
+	CompressionType         CompressionType
+	BlockRestartInterval    int
+	ValueThresholdInBytes   uint64
+	ValueLogFileSizeInBytes uint64
 }
 
 func DefaultOptions() *Options {
 	return &Options{
 		MemtableSizeInBytes:     32 * 1024 * 1024,
 		SSTableBlockSizeInBytes: 4096,
+		SSTableSizeInBytes:      32 * 1024 * 1024,
+		MaxLevels:               7,
+		Level0MaxTables:         4,
+		Level1MaxSizeInBytes:    128 * 1024 * 1024,
+		LevelSizeMultiplier:     10,
+		BloomFilterBitsPerKey:   10,
+		WALSyncPolicy:           WALSyncNever,
+		WALSyncInterval:         100 * time.Millisecond,
+		IsolationLevel:          SerializableSnapshotIsolation,
+		MaxBatchEntries:         1_000_000,
+		MaxBatchSizeInBytes:     16 * 1024 * 1024,
+		RejectDuplicateKeys:     false,
+		MaxConflictRetries:      10,
+		ConflictRetryBackoff:    time.Millisecond,
+		BlockCacheSizeInBytes:   64 * 1024 * 1024,
+		PinLevel0IndexAndFilter: false,
+		CompressionType:         NoCompression,
+		BlockRestartInterval:    16,
+		ValueThresholdInBytes:   0,
+		ValueLogFileSizeInBytes: 1024 * 1024 * 1024,
 	}
 }
 
@@ -22,3 +102,118 @@ func (options *Options) SetMemtableSizeInBytes(memtableSize uint64) *Options {
 	options.MemtableSizeInBytes = memtableSize
 	return options
 }
+
+func (options *Options) SetSSTableSizeInBytes(tableSize uint64) *Options {
+	options.SSTableSizeInBytes = tableSize
+	return options
+}
+
+func (options *Options) SetMaxLevels(maxLevels int) *Options {
+	options.MaxLevels = maxLevels
+	return options
+}
+
+func (options *Options) SetLevel0MaxTables(maxTables int) *Options {
+	options.Level0MaxTables = maxTables
+	return options
+}
+
+func (options *Options) SetLevel1MaxSizeInBytes(maxSize uint64) *Options {
+	options.Level1MaxSizeInBytes = maxSize
+	return options
+}
+
+func (options *Options) SetLevelSizeMultiplier(multiplier uint64) *Options {
+	options.LevelSizeMultiplier = multiplier
+	return options
+}
+
+func (options *Options) SetBloomFilterBitsPerKey(bitsPerKey int) *Options {
+	options.BloomFilterBitsPerKey = bitsPerKey
+	return options
+}
+
+func (options *Options) SetWALSyncPolicy(policy WALSyncPolicy) *Options {
+	options.WALSyncPolicy = policy
+	return options
+}
+
+func (options *Options) SetWALSyncInterval(interval time.Duration) *Options {
+	options.WALSyncInterval = interval
+	return options
+}
+
+func (options *Options) SetIsolationLevel(level IsolationLevel) *Options {
+	options.IsolationLevel = level
+	return options
+}
+
+func (options *Options) SetMaxBatchEntries(maxEntries int) *Options {
+	options.MaxBatchEntries = maxEntries
+	return options
+}
+
+func (options *Options) SetMaxBatchSizeInBytes(maxSize uint64) *Options {
+	options.MaxBatchSizeInBytes = maxSize
+	return options
+}
+
+func (options *Options) SetRejectDuplicateKeys(reject bool) *Options {
+	options.RejectDuplicateKeys = reject
+	return options
+}
+
+// SetTransactionLeakDeadline enables a debug mode in which the transactions that are not discarded within the deadline are reported.
+func (options *Options) SetTransactionLeakDeadline(deadline time.Duration) *Options {
+	options.TransactionLeakDeadline = deadline
+	return options
+}
+
+func (options *Options) SetMaxConflictRetries(maxRetries int) *Options {
+	options.MaxConflictRetries = maxRetries
+	return options
+}
+
+func (options *Options) SetConflictRetryBackoff(backoff time.Duration) *Options {
+	options.ConflictRetryBackoff = backoff
+	return options
+}
+
+// SetBlockCacheSizeInBytes sets the capacity of the cache of the SSTable blocks, 0 disables the cache.
+func (options *Options) SetBlockCacheSizeInBytes(cacheSize uint64) *Options {
+	options.BlockCacheSizeInBytes = cacheSize
+	return options
+}
+
+// SetPinLevel0IndexAndFilter pins the index blocks and the bloom filters of the SSTables in level 0 in the block cache,
+// so they are never evicted. It has no effect without the block cache.
+func (options *Options) SetPinLevel0IndexAndFilter(pin bool) *Options {
+	options.PinLevel0IndexAndFilter = pin
+	return options
+}
+
+func (options *Options) SetCompressionType(compressionType CompressionType) *Options {
+	options.CompressionType = compressionType
+	return options
+}
+
+// SetBlockRestartInterval sets the number of entries between the restart points of the SSTable blocks.
+// The keys between the restart points are stored as deltas of the previous keys, 1 (or less) stores every key completely.
+func (options *Options) SetBlockRestartInterval(interval int) *Options {
+	options.BlockRestartInterval = interval
+	return options
+}
+
+// SetValueThresholdInBytes sets the size above which a value is stored in the value log, and the LSM stores only a pointer
+// to it. 0 disables the value log, all the values are stored in the LSM.
+func (options *Options) SetValueThresholdInBytes(threshold uint64) *Options {
+	options.ValueThresholdInBytes = threshold
+	return options
+}
+
+// SetValueLogFileSizeInBytes sets the size of a value log file, after which a new file is started. It must not exceed
+// 4GiB, because a value pointer holds a 32-bit offset; the workspace does not open with a larger size.
+func (options *Options) SetValueLogFileSizeInBytes(fileSize uint64) *Options {
+	options.ValueLogFileSizeInBytes = fileSize
+	return options
+}
@@ -0,0 +1,189 @@
+package sstable
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// blockCacheShardBits is the number of bits of the hash of a blockCacheKey that select its shard (refer to shardFor).
+const blockCacheShardBits = 4
+
+// blockCacheShards is the number of shards of the BlockCache.
+const blockCacheShards = 1 << blockCacheShardBits
+
+// blockCacheKey identifies a block by the file id of its SSTable and its offset in the SSTable file.
+// The file ids are never reused, so the blocks of a removed SSTable are never returned for another SSTable.
+type blockCacheKey struct {
+	fileId      uint64
+	blockOffset uint32
+}
+
+// blockCacheEntry is an entry of the BlockCache. A pinned entry is not a part of the LRU list, so it is never evicted.
+type blockCacheEntry struct {
+	key         blockCacheKey
+	block       any
+	sizeInBytes uint64
+	element     *list.Element
+}
+
+// BlockCache is a sharded LRU cache of the decoded blocks of SSTables: the data blocks (*Block), the IndexBlocks and the BloomFilters.
+// BlockCache is shared by all the TableReaders of a kv.Workspace, so the blocks read by one transaction are served
+// from memory to all the concurrent transactions. The decoded blocks are never changed, so they are safe to share.
+// The capacity is split evenly across the shards, and every shard evicts its least recently used blocks once the size
+// of its blocks exceeds its capacity. Every shard has its own lock, which reduces the contention between the readers.
+// A pinned block (refer to pin) counts towards the capacity but is never evicted, it is removed explicitly.
+// A nil BlockCache caches nothing.
+type BlockCache struct {
+	shards    [blockCacheShards]*blockCacheShard
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// blockCacheShard is a shard of the BlockCache. The front of the lru list is the most recently used block.
+type blockCacheShard struct {
+	lock        sync.Mutex
+	capacity    uint64
+	sizeInBytes uint64
+	entries     map[blockCacheKey]*blockCacheEntry
+	lru         *list.List
+}
+
+// BlockCacheStatistics represents the outcomes of the lookups in the BlockCache.
+// Hits is the number of lookups served from the BlockCache, Misses is the number of lookups that had to read the block
+// from the SSTable file, and Evictions is the number of blocks evicted to make room for the other blocks.
+type BlockCacheStatistics struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRate returns the fraction of the lookups that were served from the BlockCache.
+func (statistics BlockCacheStatistics) HitRate() float64 {
+	if statistics.Hits+statistics.Misses == 0 {
+		return 0
+	}
+	return float64(statistics.Hits) / float64(statistics.Hits+statistics.Misses)
+}
+
+// NewBlockCache creates a new instance of BlockCache that holds upto capacityInBytes of blocks.
+func NewBlockCache(capacityInBytes uint64) *BlockCache {
+	blockCache := &BlockCache{}
+	for index := range blockCache.shards {
+		blockCache.shards[index] = &blockCacheShard{
+			capacity: capacityInBytes / blockCacheShards,
+			entries:  make(map[blockCacheKey]*blockCacheEntry),
+			lru:      list.New(),
+		}
+	}
+	return blockCache
+}
+
+// Statistics returns the BlockCacheStatistics since the BlockCache was created.
+func (blockCache *BlockCache) Statistics() BlockCacheStatistics {
+	if blockCache == nil {
+		return BlockCacheStatistics{}
+	}
+	return BlockCacheStatistics{
+		Hits:      blockCache.hits.Load(),
+		Misses:    blockCache.misses.Load(),
+		Evictions: blockCache.evictions.Load(),
+	}
+}
+
+// get returns the block at the blockOffset of the SSTable with the fileId, and marks it as the most recently used block.
+func (blockCache *BlockCache) get(fileId uint64, blockOffset uint32) (any, bool) {
+	if blockCache == nil {
+		return nil, false
+	}
+	key := blockCacheKey{fileId: fileId, blockOffset: blockOffset}
+	shard := blockCache.shardFor(key)
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	entry, ok := shard.entries[key]
+	if !ok {
+		blockCache.misses.Add(1)
+		return nil, false
+	}
+	if entry.element != nil {
+		shard.lru.MoveToFront(entry.element)
+	}
+	blockCache.hits.Add(1)
+	return entry.block, true
+}
+
+// put caches the block at the blockOffset of the SSTable with the fileId, evicting the least recently used blocks if needed.
+// A block that is larger than the capacity of its shard is not cached.
+func (blockCache *BlockCache) put(fileId uint64, blockOffset uint32, block any, sizeInBytes uint64) {
+	blockCache.add(blockCacheKey{fileId: fileId, blockOffset: blockOffset}, block, sizeInBytes, false)
+}
+
+// pin caches the block at the blockOffset of the SSTable with the fileId, and the block is never evicted (refer to remove).
+func (blockCache *BlockCache) pin(fileId uint64, blockOffset uint32, block any, sizeInBytes uint64) {
+	blockCache.add(blockCacheKey{fileId: fileId, blockOffset: blockOffset}, block, sizeInBytes, true)
+}
+
+// remove removes the block at the blockOffset of the SSTable with the fileId, pinned or not.
+func (blockCache *BlockCache) remove(fileId uint64, blockOffset uint32) {
+	if blockCache == nil {
+		return
+	}
+	key := blockCacheKey{fileId: fileId, blockOffset: blockOffset}
+	shard := blockCache.shardFor(key)
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if entry, ok := shard.entries[key]; ok {
+		shard.removeEntry(entry)
+	}
+}
+
+// add adds (or replaces) the block in its shard, and evicts the least recently used blocks of the shard till the size
+// of the shard is within its capacity.
+func (blockCache *BlockCache) add(key blockCacheKey, block any, sizeInBytes uint64, pinned bool) {
+	if blockCache == nil {
+		return
+	}
+	shard := blockCache.shardFor(key)
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if !pinned && sizeInBytes > shard.capacity {
+		return
+	}
+	if existing, ok := shard.entries[key]; ok {
+		shard.removeEntry(existing)
+	}
+	entry := &blockCacheEntry{key: key, block: block, sizeInBytes: sizeInBytes}
+	if !pinned {
+		entry.element = shard.lru.PushFront(entry)
+	}
+	shard.entries[key] = entry
+	shard.sizeInBytes += sizeInBytes
+
+	for shard.sizeInBytes > shard.capacity && shard.lru.Len() > 0 {
+		shard.removeEntry(shard.lru.Back().Value.(*blockCacheEntry))
+		blockCache.evictions.Add(1)
+	}
+}
+
+// shardFor returns the shard of the key. The key is mixed with a multiplicative hash, because the block offsets of
+// different SSTables are mostly the same.
+func (blockCache *BlockCache) shardFor(key blockCacheKey) *blockCacheShard {
+	hash := (key.fileId<<32 | uint64(key.blockOffset)) * 0x9E3779B97F4A7C15
+	return blockCache.shards[hash>>(64-blockCacheShardBits)]
+}
+
+// removeEntry removes the entry from the shard. It is called with the lock of the shard held.
+func (shard *blockCacheShard) removeEntry(entry *blockCacheEntry) {
+	if entry.element != nil {
+		shard.lru.Remove(entry.element)
+	}
+	delete(shard.entries, entry.key)
+	shard.sizeInBytes -= entry.sizeInBytes
+}
@@ -0,0 +1,89 @@
+package sstable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// blockOffsetsInTheSameShard returns the offsets of the blocks of the SSTable with the fileId that fall in the same shard.
+func blockOffsetsInTheSameShard(blockCache *BlockCache, fileId uint64, total int) []uint32 {
+	shard := blockCache.shardFor(blockCacheKey{fileId: fileId, blockOffset: 0})
+	offsets := []uint32{0}
+	for offset := uint32(1); len(offsets) < total; offset++ {
+		if blockCache.shardFor(blockCacheKey{fileId: fileId, blockOffset: offset}) == shard {
+			offsets = append(offsets, offset)
+		}
+	}
+	return offsets
+}
+
+func TestGetsACachedBlock(t *testing.T) {
+	blockCache := NewBlockCache(1024 * blockCacheShards)
+	blockCache.put(1, 0, "block", 10)
+
+	block, ok := blockCache.get(1, 0)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "block", block)
+
+	_, ok = blockCache.get(2, 0)
+	assert.Equal(t, false, ok)
+
+	statistics := blockCache.Statistics()
+	assert.Equal(t, BlockCacheStatistics{Hits: 1, Misses: 1}, statistics)
+	assert.Equal(t, 0.5, statistics.HitRate())
+}
+
+func TestEvictsTheLeastRecentlyUsedBlock(t *testing.T) {
+	blockCache := NewBlockCache(100 * blockCacheShards)
+	offsets := blockOffsetsInTheSameShard(blockCache, 1, 3)
+
+	blockCache.put(1, offsets[0], "first", 40)
+	blockCache.put(1, offsets[1], "second", 40)
+	_, _ = blockCache.get(1, offsets[0])
+	blockCache.put(1, offsets[2], "third", 40)
+
+	_, ok := blockCache.get(1, offsets[1])
+	assert.Equal(t, false, ok)
+	_, ok = blockCache.get(1, offsets[0])
+	assert.Equal(t, true, ok)
+	_, ok = blockCache.get(1, offsets[2])
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(1), blockCache.Statistics().Evictions)
+}
+
+func TestDoesNotEvictAPinnedBlock(t *testing.T) {
+	blockCache := NewBlockCache(100 * blockCacheShards)
+	offsets := blockOffsetsInTheSameShard(blockCache, 1, 3)
+
+	blockCache.pin(1, offsets[0], "index", 60)
+	blockCache.put(1, offsets[1], "first", 30)
+	blockCache.put(1, offsets[2], "second", 30)
+
+	_, ok := blockCache.get(1, offsets[0])
+	assert.Equal(t, true, ok)
+	_, ok = blockCache.get(1, offsets[1])
+	assert.Equal(t, false, ok)
+	_, ok = blockCache.get(1, offsets[2])
+	assert.Equal(t, true, ok)
+
+	blockCache.remove(1, offsets[0])
+	_, ok = blockCache.get(1, offsets[0])
+	assert.Equal(t, false, ok)
+}
+
+func TestDoesNotCacheABlockLargerThanTheCapacityOfItsShard(t *testing.T) {
+	blockCache := NewBlockCache(100 * blockCacheShards)
+	blockCache.put(1, 0, "block", 101)
+
+	_, ok := blockCache.get(1, 0)
+	assert.Equal(t, false, ok)
+}
+
+func TestANilBlockCacheCachesNothing(t *testing.T) {
+	var blockCache *BlockCache
+	blockCache.put(1, 0, "block", 10)
+
+	_, ok := blockCache.get(1, 0)
+	assert.Equal(t, false, ok)
+	assert.Equal(t, BlockCacheStatistics{}, blockCache.Statistics())
+}
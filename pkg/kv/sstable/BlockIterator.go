@@ -1,16 +1,22 @@
 package sstable
 
 import (
+	"encoding/binary"
 	"io"
 	"sort"
 	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/sstable/errors"
 )
 
+// BlockIterator iterates over the entries of a Block.
+// The keys of the entries are prefix-compressed (refer to Entry), so the key of an entry is reconstructed from the key
+// of the previous entry. nextOffset is the offset of the entry that follows the entry the BlockIterator is positioned at.
 type BlockIterator struct {
-	block *Block
-	key   *mvcc.VersionedKey
-	value *mvcc.Value
-	err   error
+	block      *Block
+	nextOffset int
+	key        *mvcc.VersionedKey
+	value      *mvcc.Value
+	err        error
 }
 
 func NewBlockIterator(block *Block) *BlockIterator {
@@ -19,47 +25,100 @@ func NewBlockIterator(block *Block) *BlockIterator {
 	}
 }
 
+// Seek positions the BlockIterator at the first entry whose key is greater than or equal to the key.
+// The restart points store complete keys, so Seek finds the last restart point whose key is less than the key, and
+// scans the entries from there.
 func (blockIterator *BlockIterator) Seek(key mvcc.VersionedKey) {
-	totalEntriesInBlock := len(blockIterator.block.entryBeginOffsets)
-	index := sort.Search(totalEntriesInBlock, func(index int) bool {
-		blockIterator.initializeAt(index)
+	restartOffsets := blockIterator.block.restartOffsets
+	if len(restartOffsets) == 0 {
+		blockIterator.err = io.EOF
+		return
+	}
+	index := sort.Search(len(restartOffsets), func(index int) bool {
+		blockIterator.initializeAt(int(restartOffsets[index]), nil)
 		return blockIterator.key.Compare(key) >= 0
 	})
-	blockIterator.initializeAt(index)
+	if index > 0 {
+		index = index - 1
+	}
+	blockIterator.initializeAt(int(restartOffsets[index]), nil)
+	for blockIterator.IsValid() && blockIterator.key.Compare(key) < 0 {
+		blockIterator.Next()
+	}
+}
+
+// SeekToFirst positions the BlockIterator at the first entry of the block.
+func (blockIterator *BlockIterator) SeekToFirst() {
+	blockIterator.initializeAt(0, nil)
+}
+
+// Next moves the BlockIterator to the next entry. It is ESSENTIAL to call IsValid() before calling Next.
+func (blockIterator *BlockIterator) Next() {
+	blockIterator.initializeAt(blockIterator.nextOffset, blockIterator.key.KeySlice())
+}
+
+// IsValid returns true if the BlockIterator is positioned at an entry, false otherwise.
+func (blockIterator *BlockIterator) IsValid() bool {
+	return blockIterator.err == nil && blockIterator.key != nil
+}
+
+// Key returns the mvcc.VersionedKey of the entry the BlockIterator is positioned at.
+func (blockIterator *BlockIterator) Key() mvcc.VersionedKey {
+	return *blockIterator.key
+}
+
+// Value returns the mvcc.Value of the entry the BlockIterator is positioned at.
+func (blockIterator *BlockIterator) Value() mvcc.Value {
+	return *blockIterator.value
 }
 
-func (blockIterator *BlockIterator) initializeAt(index int) {
-	if index >= len(blockIterator.block.entryBeginOffsets) || index < 0 {
+// seekToLast positions the BlockIterator at the last entry of the block, scanning from the last restart point.
+func (blockIterator *BlockIterator) seekToLast() {
+	restartOffsets := blockIterator.block.restartOffsets
+	if len(restartOffsets) == 0 {
 		blockIterator.err = io.EOF
 		return
 	}
-	getEntryHeader := func(entryBeginOffset uint32) *EntryHeader {
-		entryHeader := new(EntryHeader)
-		entryHeader.decodeFrom(blockIterator.block.buffer[entryBeginOffset:])
-
-		return entryHeader
-	}
-	getKeyValueAsBytes := func(entryBeginOffset uint32, entryHeader *EntryHeader) []byte {
-		return blockIterator.block.buffer[entryBeginOffset : entryBeginOffset+uint32(entryHeader.entrySize)]
+	blockIterator.initializeAt(int(restartOffsets[len(restartOffsets)-1]), nil)
+	for blockIterator.IsValid() && blockIterator.nextOffset < blockIterator.block.dataEndOffset {
+		blockIterator.Next()
 	}
-	getKey := func(keyValueBytes []byte, entryHeader *EntryHeader) *mvcc.VersionedKey {
-		keyBytes := keyValueBytes[entryHeaderSize : entryHeaderSize+entryHeader.keySize]
-		versionedKey := new(mvcc.VersionedKey)
-		versionedKey.DecodeFrom(keyBytes)
+}
 
-		return versionedKey
+// initializeAt positions the BlockIterator at the entry which begins at the offset.
+// previousKey is the key (without the version) of the previous entry, it is nil for the entries at the restart points.
+// The key of every entry gets its own byte slice, because the keys returned by the BlockIterator outlive its position.
+func (blockIterator *BlockIterator) initializeAt(offset int, previousKey []byte) {
+	if offset < 0 || offset >= blockIterator.block.dataEndOffset {
+		blockIterator.err = io.EOF
+		return
 	}
-	getValue := func(keyValueBytes []byte, entryHeader *EntryHeader) *mvcc.Value {
-		valueBytes := keyValueBytes[entryHeaderSize+entryHeader.keySize:]
-		value := new(mvcc.Value)
-		value.DecodeFrom(valueBytes)
+	buffer := blockIterator.block.buffer
 
-		return value
+	entryHeader := new(EntryHeader)
+	headerSize, err := entryHeader.decodeFrom(buffer[offset:blockIterator.block.dataEndOffset])
+	if err == nil && (int(entryHeader.sharedKeySize) > len(previousKey) ||
+		offset+headerSize+entryHeader.entrySize() > blockIterator.block.dataEndOffset) {
+		err = errors.CorruptDataBlockErr
+	}
+	if err != nil {
+		blockIterator.err = err
+		return
 	}
-	entryBeginOffset := blockIterator.block.entryBeginOffsets[index]
-	entryHeader := getEntryHeader(entryBeginOffset)
-	keyValueBytes := getKeyValueAsBytes(entryBeginOffset, entryHeader)
+	versionBegin := offset + headerSize
+	unsharedKeyBegin := versionBegin + uint64Size
+	valueBegin := unsharedKeyBegin + int(entryHeader.unsharedKeySize)
+
+	key := make([]byte, 0, int(entryHeader.sharedKeySize)+int(entryHeader.unsharedKeySize))
+	key = append(key, previousKey[:entryHeader.sharedKeySize]...)
+	key = append(key, buffer[unsharedKeyBegin:valueBegin]...)
+	versionedKey := mvcc.NewVersionedKey(key, binary.LittleEndian.Uint64(buffer[versionBegin:]))
+
+	value := new(mvcc.Value)
+	value.DecodeFrom(buffer[valueBegin : valueBegin+int(entryHeader.valueSize)])
 
-	blockIterator.key = getKey(keyValueBytes, entryHeader)
-	blockIterator.value = getValue(keyValueBytes, entryHeader)
+	blockIterator.nextOffset = offset + headerSize + entryHeader.entrySize()
+	blockIterator.err = nil
+	blockIterator.key = &versionedKey
+	blockIterator.value = value
 }
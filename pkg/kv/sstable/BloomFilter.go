@@ -0,0 +1,96 @@
+package sstable
+
+import (
+	"hash/fnv"
+)
+
+const (
+	minHashFunctions = 1
+	maxHashFunctions = 30
+)
+
+// BloomFilter is a probabilistic structure that tells if a key is definitely absent from an SSTable, or may be present.
+// BloomFilter is built over the keys without the version, so all the versions of a key share the same bits.
+// It uses double hashing (from [LevelDB](https://github.com/google/leveldb)): a single 32 bits hash of the key is
+// computed and k hashes are derived by repeatedly adding a delta (the rotated hash) to it.
+/*
+Structure of an encoded BloomFilter.
++---------------------+-------------------------------+
+| bits                | 1 byte number of hash funcs   |
++---------------------+-------------------------------+
+*/
+type BloomFilter struct {
+	bits               []byte
+	totalHashFunctions uint8
+}
+
+// newBloomFilter creates a new instance of BloomFilter over the hashes of the keys, using bitsPerKey bits per key.
+// The number of hash functions that minimizes the false positive rate is bitsPerKey * ln(2).
+func newBloomFilter(keyHashes []uint32, bitsPerKey int) *BloomFilter {
+	totalHashFunctions := int(float64(bitsPerKey) * 0.69)
+	if totalHashFunctions < minHashFunctions {
+		totalHashFunctions = minHashFunctions
+	}
+	if totalHashFunctions > maxHashFunctions {
+		totalHashFunctions = maxHashFunctions
+	}
+	totalBits := len(keyHashes) * bitsPerKey
+	if totalBits < 64 {
+		totalBits = 64
+	}
+	totalBytes := (totalBits + 7) / 8
+	totalBits = totalBytes * 8
+
+	filter := &BloomFilter{bits: make([]byte, totalBytes), totalHashFunctions: uint8(totalHashFunctions)}
+	for _, hash := range keyHashes {
+		delta := hash>>17 | hash<<15
+		for index := 0; index < totalHashFunctions; index++ {
+			bitPosition := hash % uint32(totalBits)
+			filter.bits[bitPosition/8] |= 1 << (bitPosition % 8)
+			hash = hash + delta
+		}
+	}
+	return filter
+}
+
+// MayContain returns false if the key is definitely absent, true if the key may be present.
+func (filter *BloomFilter) MayContain(key []byte) bool {
+	totalBits := uint32(len(filter.bits) * 8)
+	if totalBits == 0 {
+		return true
+	}
+	hash := hashOf(key)
+	delta := hash>>17 | hash<<15
+	for index := 0; index < int(filter.totalHashFunctions); index++ {
+		bitPosition := hash % totalBits
+		if filter.bits[bitPosition/8]&(1<<(bitPosition%8)) == 0 {
+			return false
+		}
+		hash = hash + delta
+	}
+	return true
+}
+
+// encode encodes the BloomFilter.
+func (filter *BloomFilter) encode() []byte {
+	encoded := make([]byte, 0, len(filter.bits)+1)
+	encoded = append(encoded, filter.bits...)
+	return append(encoded, filter.totalHashFunctions)
+}
+
+// decodeBloomFilter decodes the BloomFilter from the byte slice.
+// An empty byte slice decodes to a BloomFilter that may contain every key, which is the case when the SSTable
+// was built without a BloomFilter.
+func decodeBloomFilter(buffer []byte) *BloomFilter {
+	if len(buffer) == 0 {
+		return &BloomFilter{}
+	}
+	return &BloomFilter{bits: buffer[:len(buffer)-1], totalHashFunctions: buffer[len(buffer)-1]}
+}
+
+// hashOf returns the 32 bits FNV-1a hash of the key.
+func hashOf(key []byte) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write(key)
+	return hash.Sum32()
+}
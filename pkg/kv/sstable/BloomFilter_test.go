@@ -0,0 +1,53 @@
+package sstable
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func keyHashesOf(keys ...string) []uint32 {
+	var keyHashes []uint32
+	for _, key := range keys {
+		keyHashes = append(keyHashes, hashOf([]byte(key)))
+	}
+	return keyHashes
+}
+
+func TestBloomFilterMayContainAllTheAddedKeys(t *testing.T) {
+	filter := newBloomFilter(keyHashesOf("HDD", "SSD", "NVMe"), 10)
+
+	assert.True(t, filter.MayContain([]byte("HDD")))
+	assert.True(t, filter.MayContain([]byte("SSD")))
+	assert.True(t, filter.MayContain([]byte("NVMe")))
+}
+
+func TestBloomFilterDoesNotContainMostOfTheKeysThatAreNotAdded(t *testing.T) {
+	var keys []string
+	for count := 0; count < 1000; count++ {
+		keys = append(keys, fmt.Sprintf("key-%d", count))
+	}
+	filter := newBloomFilter(keyHashesOf(keys...), 10)
+
+	falsePositives := 0
+	for count := 0; count < 1000; count++ {
+		if filter.MayContain([]byte(fmt.Sprintf("absent-%d", count))) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 50)
+}
+
+func TestEncodesAndDecodesTheBloomFilter(t *testing.T) {
+	filter := newBloomFilter(keyHashesOf("HDD", "SSD"), 10)
+	decoded := decodeBloomFilter(filter.encode())
+
+	assert.Equal(t, filter.totalHashFunctions, decoded.totalHashFunctions)
+	assert.True(t, decoded.MayContain([]byte("HDD")))
+	assert.True(t, decoded.MayContain([]byte("SSD")))
+}
+
+func TestAnEmptyBloomFilterMayContainAnyKey(t *testing.T) {
+	filter := decodeBloomFilter(nil)
+	assert.True(t, filter.MayContain([]byte("HDD")))
+}
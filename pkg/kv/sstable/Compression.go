@@ -0,0 +1,103 @@
+package sstable
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable/errors"
+)
+
+// compressionTrailerSize is the size of the trailer that follows every data block in an SSTable file.
+// The trailer is a single byte that holds the option.CompressionType the data block is stored with.
+const compressionTrailerSize = 1
+
+// minimumCompressionSavingsFraction decides if a compressed data block is worth storing: a data block is stored
+// compressed only if compression saves at least 1/minimumCompressionSavingsFraction of its size, else it is stored raw.
+const minimumCompressionSavingsFraction = 8
+
+// Codec compresses and decompresses the data blocks of SSTables.
+// A Codec must be safe for concurrent use, because the data blocks are decompressed by concurrent readers.
+type Codec interface {
+	Compress(raw []byte) ([]byte, error)
+	Decompress(compressed []byte) ([]byte, error)
+}
+
+// codecs maps an option.CompressionType to its Codec. option.NoCompression does not have a Codec.
+var codecs = map[option.CompressionType]Codec{
+	option.FlateCompression: flateCodec{},
+}
+
+// RegisterCodec registers the Codec for the compressionType, replacing the existing Codec (if any).
+// The compressionType is stored in the SSTable files, so it must not change its meaning across the runs.
+// RegisterCodec is not safe for concurrent use, it should be called before any SSTable is built or read (for example, in init).
+func RegisterCodec(compressionType option.CompressionType, codec Codec) {
+	codecs[compressionType] = codec
+}
+
+// encodeDataBlock returns the data block followed by the compression trailer.
+// The data block is compressed with the Codec of the compressionType, and it falls back to being stored raw
+// (option.NoCompression in the trailer) if it compresses poorly (refer to minimumCompressionSavingsFraction).
+func encodeDataBlock(raw []byte, compressionType option.CompressionType) ([]byte, error) {
+	stored, storedType := raw, option.NoCompression
+	if compressionType != option.NoCompression {
+		codec, ok := codecs[compressionType]
+		if !ok {
+			return nil, errors.UnsupportedCompressionTypeErr
+		}
+		compressed, err := codec.Compress(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) <= len(raw)-len(raw)/minimumCompressionSavingsFraction {
+			stored, storedType = compressed, compressionType
+		}
+	}
+	encoded := make([]byte, 0, len(stored)+compressionTrailerSize)
+	encoded = append(encoded, stored...)
+	return append(encoded, byte(storedType)), nil
+}
+
+// decodeDataBlock removes the compression trailer from the data block read from an SSTable file, and decompresses
+// the data block with the Codec of the option.CompressionType in the trailer.
+func decodeDataBlock(encoded []byte) ([]byte, error) {
+	if len(encoded) < compressionTrailerSize {
+		return nil, errors.CorruptDataBlockErr
+	}
+	stored := encoded[:len(encoded)-compressionTrailerSize]
+	compressionType := option.CompressionType(encoded[len(encoded)-compressionTrailerSize])
+	if compressionType == option.NoCompression {
+		return stored, nil
+	}
+	codec, ok := codecs[compressionType]
+	if !ok {
+		return nil, errors.UnsupportedCompressionTypeErr
+	}
+	return codec.Decompress(stored)
+}
+
+// flateCodec is the Codec for option.FlateCompression, it uses compress/flate with the default compression level.
+type flateCodec struct{}
+
+// Compress compresses the raw bytes with flate.
+func (flateCodec) Compress(raw []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decompress decompresses the bytes compressed by Compress.
+func (flateCodec) Decompress(compressed []byte) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(compressed))
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
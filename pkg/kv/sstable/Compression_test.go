@@ -0,0 +1,84 @@
+package sstable
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable/errors"
+)
+
+var repetitiveDocument = bytes.Repeat([]byte(`{"type":"disk","name":"Hard disk","capacity":"1TB"}`), 20)
+
+func TestEncodesAndDecodesACompressedDataBlock(t *testing.T) {
+	encoded, err := encodeDataBlock(repetitiveDocument, option.FlateCompression)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(option.FlateCompression), encoded[len(encoded)-1])
+	assert.True(t, len(encoded) < len(repetitiveDocument))
+
+	decoded, err := decodeDataBlock(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, repetitiveDocument, decoded)
+}
+
+func TestStoresADataBlockThatCompressesPoorlyRaw(t *testing.T) {
+	random := make([]byte, 512)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	encoded, err := encodeDataBlock(random, option.FlateCompression)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(option.NoCompression), encoded[len(encoded)-1])
+	assert.Equal(t, random, encoded[:len(encoded)-1])
+
+	decoded, err := decodeDataBlock(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, random, decoded)
+}
+
+func TestEncodesADataBlockWithoutCompression(t *testing.T) {
+	encoded, err := encodeDataBlock(repetitiveDocument, option.NoCompression)
+	assert.Nil(t, err)
+	assert.Equal(t, append(append([]byte{}, repetitiveDocument...), byte(option.NoCompression)), encoded)
+}
+
+func TestDecodesADataBlockWithAnUnsupportedCompressionType(t *testing.T) {
+	_, err := decodeDataBlock([]byte{1, 2, 3, 200})
+	assert.Equal(t, errors.UnsupportedCompressionTypeErr, err)
+
+	_, err = decodeDataBlock([]byte{})
+	assert.Equal(t, errors.CorruptDataBlockErr, err)
+}
+
+// reversingCodec is a Codec that is only good for testing the registration of a Codec.
+type reversingCodec struct{}
+
+func (reversingCodec) Compress(raw []byte) ([]byte, error) {
+	return reverse(raw)[:len(raw)/2], nil
+}
+
+func (reversingCodec) Decompress(compressed []byte) ([]byte, error) {
+	return append(compressed, compressed...), nil
+}
+
+func reverse(part []byte) []byte {
+	reversed := make([]byte, len(part))
+	for index := range part {
+		reversed[len(part)-1-index] = part[index]
+	}
+	return reversed
+}
+
+func TestEncodesAndDecodesADataBlockWithARegisteredCodec(t *testing.T) {
+	customCompression := option.CompressionType(100)
+	RegisterCodec(customCompression, reversingCodec{})
+	defer delete(codecs, customCompression)
+
+	encoded, err := encodeDataBlock([]byte("abab"), customCompression)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{'b', 'a', byte(customCompression)}, encoded)
+
+	decoded, err := decodeDataBlock(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("baba"), decoded)
+}
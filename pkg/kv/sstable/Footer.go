@@ -0,0 +1,69 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"tinydb/pkg/kv/sstable/errors"
+	"unsafe"
+)
+
+// magicNumber is placed at the end of every SSTable file. It spells "tinydbSS".
+const magicNumber = uint64(0x7469_6e79_6462_5353)
+
+// formatVersion is the version of the SSTable file format written by the TableBuilder.
+const formatVersion = uint32(6)
+
+const footerSize = int(unsafe.Sizeof(uint32(0)))*5 + int(unsafe.Sizeof(uint64(0)))*2
+
+// Footer is the fixed size trailer of an SSTable file.
+// The Footer also records the maxVersion, which is the largest commitTimestamp of all the keys present in the SSTable.
+// Format version 3 adds the offset and the size of the filter block (the encoded BloomFilter).
+// Format version 4 adds the compression trailer after every data block (refer to encodeDataBlock).
+// Format version 5 adds the restart points at the end of every data block, and prefix-compresses the keys between them.
+// Format version 6 encodes the sizes in the entry headers (and the key sizes of the index block) as varints, which
+// removes the per-block limit on the size of a key or a value.
+/*
+Structure of the Footer.
++-----------------------------+---------------------------+------------------------------+----------------------------+
+| 4 bytes index block offset  | 4 bytes index block size  | 4 bytes filter block offset  | 4 bytes filter block size  |
++-----------------------------+---------------------------+------------------------------+----------------------------+
+| 8 bytes maxVersion          | 4 bytes format version    | 8 bytes magic number         |
++-----------------------------+---------------------------+------------------------------+
+*/
+type Footer struct {
+	indexBlockOffset  uint32
+	indexBlockSize    uint32
+	filterBlockOffset uint32
+	filterBlockSize   uint32
+	maxVersion        uint64
+	formatVersion     uint32
+}
+
+// encode encodes the Footer along with the magic number.
+func (footer Footer) encode() []byte {
+	encoded := make([]byte, footerSize)
+	binary.LittleEndian.PutUint32(encoded, footer.indexBlockOffset)
+	binary.LittleEndian.PutUint32(encoded[uint32Size:], footer.indexBlockSize)
+	binary.LittleEndian.PutUint32(encoded[2*uint32Size:], footer.filterBlockOffset)
+	binary.LittleEndian.PutUint32(encoded[3*uint32Size:], footer.filterBlockSize)
+	binary.LittleEndian.PutUint64(encoded[4*uint32Size:], footer.maxVersion)
+	binary.LittleEndian.PutUint32(encoded[4*uint32Size+uint64Size:], footer.formatVersion)
+	binary.LittleEndian.PutUint64(encoded[footerSize-uint64Size:], magicNumber)
+	return encoded
+}
+
+// decodeFrom decodes the Footer from the byte slice and validates the magic number and the format version.
+func (footer *Footer) decodeFrom(part []byte) error {
+	if len(part) != footerSize || binary.LittleEndian.Uint64(part[footerSize-uint64Size:]) != magicNumber {
+		return errors.InvalidMagicNumberErr
+	}
+	footer.indexBlockOffset = binary.LittleEndian.Uint32(part)
+	footer.indexBlockSize = binary.LittleEndian.Uint32(part[uint32Size:])
+	footer.filterBlockOffset = binary.LittleEndian.Uint32(part[2*uint32Size:])
+	footer.filterBlockSize = binary.LittleEndian.Uint32(part[3*uint32Size:])
+	footer.maxVersion = binary.LittleEndian.Uint64(part[4*uint32Size:])
+	footer.formatVersion = binary.LittleEndian.Uint32(part[4*uint32Size+uint64Size:])
+	if footer.formatVersion != formatVersion {
+		return errors.UnsupportedFormatVersionErr
+	}
+	return nil
+}
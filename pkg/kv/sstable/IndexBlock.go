@@ -0,0 +1,91 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"sort"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/sstable/errors"
+)
+
+// IndexBlock maps the first key of every data block to the offset and the size of the block in the SSTable file.
+// IndexBlock is written after all the data blocks and is used by the TableReader to find the block that may contain a key.
+/*
+Structure of an IndexBlock entry.
++-----------------+---------------+-----------------------+---------------------+
+| varint key size | first key     | 4 bytes block offset  | 4 bytes block size  |
++-----------------+---------------+-----------------------+---------------------+
+*/
+type IndexBlock struct {
+	entries []IndexBlockEntry
+}
+
+// IndexBlockEntry represents the position of a single data block along with its first key.
+type IndexBlockEntry struct {
+	firstKey    []byte
+	blockOffset uint32
+	blockSize   uint32
+}
+
+// add adds an IndexBlockEntry. Entries must be added in the order of the blocks.
+func (indexBlock *IndexBlock) add(firstKey []byte, blockOffset uint32, blockSize uint32) {
+	indexBlock.entries = append(indexBlock.entries, IndexBlockEntry{
+		firstKey:    firstKey,
+		blockOffset: blockOffset,
+		blockSize:   blockSize,
+	})
+}
+
+// blockIndexFor returns the index of the last block whose first key is less than or equal to the incoming key.
+// If the incoming key is smaller than the first key of the first block, 0 is returned.
+func (indexBlock *IndexBlock) blockIndexFor(key mvcc.VersionedKey) int {
+	index := sort.Search(len(indexBlock.entries), func(index int) bool {
+		firstKey := new(mvcc.VersionedKey)
+		firstKey.DecodeFrom(indexBlock.entries[index].firstKey)
+		return firstKey.Compare(key) > 0
+	})
+	if index == 0 {
+		return 0
+	}
+	return index - 1
+}
+
+// totalBlocks returns the number of data blocks.
+func (indexBlock *IndexBlock) totalBlocks() int {
+	return len(indexBlock.entries)
+}
+
+// encode encodes all the entries of the IndexBlock.
+func (indexBlock *IndexBlock) encode() []byte {
+	var encoded []byte
+	for _, entry := range indexBlock.entries {
+		encoded = binary.AppendUvarint(encoded, uint64(len(entry.firstKey)))
+		encoded = append(encoded, entry.firstKey...)
+		encoded = binary.LittleEndian.AppendUint32(encoded, entry.blockOffset)
+		encoded = binary.LittleEndian.AppendUint32(encoded, entry.blockSize)
+	}
+	return encoded
+}
+
+// decodeIndexBlock decodes the IndexBlock from the byte slice.
+func decodeIndexBlock(buffer []byte) (*IndexBlock, error) {
+	indexBlock := new(IndexBlock)
+	for offset := 0; offset < len(buffer); {
+		size, bytesRead := binary.Uvarint(buffer[offset:])
+		if bytesRead <= 0 || size > uint64(len(buffer)) {
+			return nil, errors.CorruptIndexBlockErr
+		}
+		keySize := int(size)
+		offset = offset + bytesRead
+		if offset+keySize+2*uint32Size > len(buffer) {
+			return nil, errors.CorruptIndexBlockErr
+		}
+		firstKey := buffer[offset : offset+keySize]
+		offset = offset + keySize
+		blockOffset := binary.LittleEndian.Uint32(buffer[offset:])
+		blockSize := binary.LittleEndian.Uint32(buffer[offset+uint32Size:])
+		offset = offset + 2*uint32Size
+
+		indexBlock.add(firstKey, blockOffset, blockSize)
+	}
+	return indexBlock, nil
+}
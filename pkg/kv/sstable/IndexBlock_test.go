@@ -0,0 +1,40 @@
+package sstable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"tinydb/pkg/kv/mvcc"
+)
+
+func TestEncodeAndDecodeTheIndexBlock(t *testing.T) {
+	indexBlock := new(IndexBlock)
+	indexBlock.add(mvcc.NewVersionedKey([]byte("HDD"), 1).Encode(), 0, 100)
+	indexBlock.add(mvcc.NewVersionedKey([]byte("SSD"), 1).Encode(), 100, 80)
+
+	decoded, err := decodeIndexBlock(indexBlock.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, decoded.totalBlocks())
+	assert.Equal(t, uint32(100), decoded.entries[1].blockOffset)
+	assert.Equal(t, uint32(80), decoded.entries[1].blockSize)
+}
+
+func TestDecodeACorruptIndexBlock(t *testing.T) {
+	indexBlock := new(IndexBlock)
+	indexBlock.add(mvcc.NewVersionedKey([]byte("HDD"), 1).Encode(), 0, 100)
+
+	encoded := indexBlock.encode()
+	_, err := decodeIndexBlock(encoded[:len(encoded)-1])
+	assert.Error(t, err)
+}
+
+func TestBlockIndexForAKey(t *testing.T) {
+	indexBlock := new(IndexBlock)
+	indexBlock.add(mvcc.NewVersionedKey([]byte("HDD"), 1).Encode(), 0, 100)
+	indexBlock.add(mvcc.NewVersionedKey([]byte("HDD"), 5).Encode(), 100, 100)
+	indexBlock.add(mvcc.NewVersionedKey([]byte("SSD"), 1).Encode(), 200, 100)
+
+	assert.Equal(t, 0, indexBlock.blockIndexFor(mvcc.NewVersionedKey([]byte("Disk"), 1)))
+	assert.Equal(t, 0, indexBlock.blockIndexFor(mvcc.NewVersionedKey([]byte("HDD"), 0)))
+	assert.Equal(t, 1, indexBlock.blockIndexFor(mvcc.NewVersionedKey([]byte("HDD"), 6)))
+	assert.Equal(t, 2, indexBlock.blockIndexFor(mvcc.NewVersionedKey([]byte("Versioning"), 1)))
+}
@@ -0,0 +1,73 @@
+package sstable
+
+import "tinydb/pkg/kv/mvcc"
+
+// MergeIterator merges the TableIterators of multiple SSTables and allows forward movement over their entries
+// in the increasing order of mvcc.VersionedKey.
+// The TableIterators are expected to be ordered from the newest SSTable to the oldest SSTable. If the same mvcc.VersionedKey
+// is present in more than one SSTable, the entry from the newest SSTable is returned and the others are skipped.
+type MergeIterator struct {
+	iterators []*TableIterator
+	current   *TableIterator
+}
+
+// NewMergeIterator creates a new instance of MergeIterator and positions it at the smallest entry of all the iterators.
+func NewMergeIterator(iterators []*TableIterator) *MergeIterator {
+	for _, iterator := range iterators {
+		iterator.SeekToFirst()
+	}
+	mergeIterator := &MergeIterator{iterators: iterators}
+	mergeIterator.moveToSmallest()
+	return mergeIterator
+}
+
+// Next moves the MergeIterator to the next entry. It is ESSENTIAL to call IsValid() before calling Next.
+// All the iterators that are positioned at the same mvcc.VersionedKey as the current entry are moved forward.
+func (mergeIterator *MergeIterator) Next() {
+	currentKey := mergeIterator.current.Key()
+	for _, iterator := range mergeIterator.iterators {
+		if iterator.IsValid() && iterator.Key().Compare(currentKey) == 0 {
+			iterator.Next()
+		}
+	}
+	mergeIterator.moveToSmallest()
+}
+
+// IsValid returns true if the MergeIterator is positioned at an entry, false otherwise.
+func (mergeIterator *MergeIterator) IsValid() bool {
+	return mergeIterator.current != nil
+}
+
+// Key returns the mvcc.VersionedKey of the entry the MergeIterator is positioned at.
+func (mergeIterator *MergeIterator) Key() mvcc.VersionedKey {
+	return mergeIterator.current.Key()
+}
+
+// Value returns the mvcc.Value of the entry the MergeIterator is positioned at.
+func (mergeIterator *MergeIterator) Value() mvcc.Value {
+	return mergeIterator.current.Value()
+}
+
+// Err returns the first error that was encountered by any of the iterators.
+func (mergeIterator *MergeIterator) Err() error {
+	for _, iterator := range mergeIterator.iterators {
+		if err := iterator.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveToSmallest positions the MergeIterator at the iterator with the smallest key.
+// In case of equal keys, the iterator that comes first (the newest SSTable) wins.
+func (mergeIterator *MergeIterator) moveToSmallest() {
+	mergeIterator.current = nil
+	for _, iterator := range mergeIterator.iterators {
+		if !iterator.IsValid() {
+			continue
+		}
+		if mergeIterator.current == nil || iterator.Key().Compare(mergeIterator.current.Key()) < 0 {
+			mergeIterator.current = iterator
+		}
+	}
+}
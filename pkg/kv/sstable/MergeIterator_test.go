@@ -0,0 +1,38 @@
+package sstable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+)
+
+func TestMergesTheEntriesOfMultipleSSTables(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+
+	builder := NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 2), mvcc.NewValue([]byte("Hard disk drive")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state drive")))
+	_ = builder.Build(2)
+	newer, _ := NewTableReader(2, options)
+	defer newer.Close()
+
+	builder = NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.Add(mvcc.NewVersionedKey([]byte("NVMe"), 1), mvcc.NewValue([]byte("Non-volatile memory")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewValue([]byte("Solid state")))
+	_ = builder.Build(1)
+	older, _ := NewTableReader(1, options)
+	defer older.Close()
+
+	iterator := NewMergeIterator([]*TableIterator{newer.NewIterator(), older.NewIterator()})
+
+	var keys, values []string
+	for ; iterator.IsValid(); iterator.Next() {
+		keys = append(keys, iterator.Key().AsString())
+		values = append(values, string(iterator.Value().ValueSlice()))
+	}
+	assert.Nil(t, iterator.Err())
+	assert.Equal(t, []string{"HDD", "HDD", "NVMe", "SSD"}, keys)
+	assert.Equal(t, []string{"Hard disk", "Hard disk drive", "Non-volatile memory", "Solid state drive"}, values)
+}
@@ -1,48 +1,89 @@
 package sstable
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable/errors"
 	"tinydb/pkg/kv/utils"
 	"unsafe"
 )
 
-const uint16Size = int(unsafe.Sizeof(uint16(0)))
-const entryHeaderSize = uint16(unsafe.Sizeof(EntryHeader{}))
+const uint32Size = int(unsafe.Sizeof(uint32(0)))
+const uint64Size = int(unsafe.Sizeof(uint64(0)))
 
+// maxEntryHeaderSize is the maximum size of an encoded EntryHeader, which has 3 varint encoded uint32 sizes.
+const maxEntryHeaderSize = 3 * binary.MaxVarintLen32
+
+// TableBuilder builds an SSTable from the key/value pairs that are added in the increasing order of mvcc.VersionedKey.
+// lastKey is the last key (without the version) that is added, and entriesSinceRestart is the number of entries added
+// to the current block since its last restart point.
 type TableBuilder struct {
-	options      *option.Options
-	currentBlock *Block
+	options             *option.Options
+	currentBlock        *Block
+	finishedBlocks      []*Block
+	maxVersion          uint64
+	keyHashes           []uint32
+	lastKey             []byte
+	entriesSinceRestart int
 }
 
 //Structure of an entry.
 /*
-+-------------------+------------------+-----+-------+
-| 2 bytes entrySize | 2 bytes key size | key | Value |
-+-------------------+------------------+-----+-------+
+The key (without the version) of an entry is stored as a delta of the key of the previous entry in the block: the size
+of the prefix it shares with the previous key, followed by the rest (unshared part) of the key.
+An entry at a restart point shares nothing with the previous key, so it stores the complete key.
++------------------------+--------------------------+-------------------+-----------------+--------------------+-------+
+| varint shared key size | varint unshared key size | varint value size | 8 bytes version | unshared key bytes | Value |
++------------------------+--------------------------+-------------------+-----------------+--------------------+-------+
 */
 
 // Block
 /*
 Structure of a Block.
+A restart point is placed at the first entry of the block, and after every BlockRestartInterval entries.
 +-------------------+---------------------+--------------------+
 | Entry1            | Entry2              | Entry3             |
 +-------------------+---------------------+--------------------+
-| List of offsets used by each key        | Block Meta Size    |
-| present in the data block               | (4 Bytes)          |
+| Offsets of the restart points           | Number of restart  |
+| (4 Bytes each)                          | points (4 Bytes)   |
 +-----------------------------------------+--------------------+
 */
+// dataEndOffset is the offset where the entries end (and the restart points begin).
 type Block struct {
-	firstKey          []byte
-	buffer            []byte
-	entryBeginOffsets []uint32
-	endOffset         int
+	firstKey       []byte
+	buffer         []byte
+	restartOffsets []uint32
+	dataEndOffset  int
+	endOffset      int
 }
 
+// Table
+/*
+Structure of an SSTable file.
+Every block is followed by a 1 byte compression trailer, and is stored compressed with the CompressionType of the options
+(or raw, if it does not compress well). Refer to encodeDataBlock.
++-------------------+---------------------+--------------------+
+| Block1 + trailer  | Block2 + trailer    | Block3 + trailer   |
++-------------------+---------------------+--------------------+
+| Index block                             | Filter block       |
+| (first key, offset and size of blocks)  | (BloomFilter)      |
++-----------------------------------------+--------------------+
+| Footer (36 Bytes)                                            |
++--------------------------------------------------------------+
+*/
+
+// EntryHeader holds the sizes of the parts of an entry, which are varint encoded, so that the small keys and values
+// take a byte or two for their sizes while the keys and values larger than 64KiB remain representable.
 type EntryHeader struct {
-	entrySize uint16
-	keySize   uint16
+	sharedKeySize   uint32
+	unsharedKeySize uint32
+	valueSize       uint32
 }
 
 func NewSSTableBuilder(options *option.Options) *TableBuilder {
@@ -51,23 +92,148 @@ func NewSSTableBuilder(options *option.Options) *TableBuilder {
 	return builder
 }
 
-// Add
-// TODO: Index block
-// TODO: Footer block
+// Add adds the key/value pair to the current block.
+// If the current block does not have the room for the key/value pair, the current block is finished and a new block is started.
+// Keys must be added in the increasing order of mvcc.VersionedKey.
+// The hash of the key (without the version) is collected for the BloomFilter, once for all the versions of the key.
+// The key is stored as a delta of the previous key, unless the entry is a restart point (refer to Entry).
 func (builder *TableBuilder) Add(key mvcc.VersionedKey, value mvcc.Value) {
-	encodedKey, encodedValue := key.Encode(), value.Encode()
+	encodedValue := value.Encode()
+	if !builder.hasRoomFor(key.KeySlice(), encodedValue) {
+		builder.finishBlock()
+		builder.finishedBlocks = append(builder.finishedBlocks, builder.currentBlock)
+		builder.currentBlock = builder.newBlock()
+	}
 	if builder.currentBlock.firstKey == nil {
-		builder.currentBlock.firstKey = encodedKey
+		builder.currentBlock.firstKey = key.Encode()
+	}
+	if key.Version > builder.maxVersion {
+		builder.maxVersion = key.Version
 	}
-	builder.currentBlock.entryBeginOffsets = append(builder.currentBlock.entryBeginOffsets, uint32(builder.currentBlock.endOffset))
-	builder.append(newEntryHeader(encodedKey, encodedValue).encode())
-	builder.append(encodedKey)
+	sharedKeySize := 0
+	if builder.isRestartPoint() {
+		builder.currentBlock.restartOffsets = append(builder.currentBlock.restartOffsets, uint32(builder.currentBlock.endOffset))
+		builder.entriesSinceRestart = 0
+	} else {
+		sharedKeySize = sharedPrefixSize(builder.lastKey, key.KeySlice())
+	}
+	builder.entriesSinceRestart++
+
+	if builder.lastKey == nil || !bytes.Equal(builder.lastKey, key.KeySlice()) {
+		builder.keyHashes = append(builder.keyHashes, hashOf(key.KeySlice()))
+		builder.lastKey = key.KeySlice()
+	}
+	unsharedKey := key.KeySlice()[sharedKeySize:]
+	builder.append(newEntryHeader(sharedKeySize, unsharedKey, encodedValue).encode())
+	builder.append(utils.U64ToBytesLittleEndian(key.Version))
+	builder.append(unsharedKey)
 	builder.append(encodedValue)
+	builder.currentBlock.dataEndOffset = builder.currentBlock.endOffset
+}
+
+// IsEmpty returns true if no key/value pair has been added to the TableBuilder.
+func (builder *TableBuilder) IsEmpty() bool {
+	return len(builder.finishedBlocks) == 0 && len(builder.currentBlock.restartOffsets) == 0
 }
 
+// SizeInBytes returns the size of all the blocks that have been added to the TableBuilder so far.
+// It does not include the block meta of the current block, the IndexBlock and the Footer.
+func (builder *TableBuilder) SizeInBytes() uint64 {
+	size := uint64(builder.currentBlock.endOffset)
+	for _, block := range builder.finishedBlocks {
+		size = size + uint64(block.endOffset)
+	}
+	return size
+}
+
+// Build finishes the current block and writes all the blocks, followed by the IndexBlock, the BloomFilter and the Footer
+// to the file identified by the fileId in the DbDirectory. The file is synced before it is closed.
+// The blocks are compressed with the CompressionType of the options, and the IndexBlock records their sizes on disk.
+// The BloomFilter is not written if BloomFilterBitsPerKey is 0.
+func (builder *TableBuilder) Build(fileId uint64) error {
+	builder.finishBlock()
+	blocks := append(builder.finishedBlocks, builder.currentBlock)
+
+	file, err := os.OpenFile(TableFilePath(fileId, builder.options.DbDirectory), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	write := func(part []byte) error {
+		_, err := file.Write(part)
+		return err
+	}
+
+	indexBlock, offset := new(IndexBlock), uint32(0)
+	for _, block := range blocks {
+		encodedBlock, err := encodeDataBlock(block.buffer[:block.endOffset], builder.options.CompressionType)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if err := write(encodedBlock); err != nil {
+			_ = file.Close()
+			return err
+		}
+		indexBlock.add(block.firstKey, offset, uint32(len(encodedBlock)))
+		offset = offset + uint32(len(encodedBlock))
+	}
+	encodedIndexBlock := indexBlock.encode()
+	if err := write(encodedIndexBlock); err != nil {
+		_ = file.Close()
+		return err
+	}
+	var encodedFilter []byte
+	if builder.options.BloomFilterBitsPerKey > 0 {
+		encodedFilter = newBloomFilter(builder.keyHashes, builder.options.BloomFilterBitsPerKey).encode()
+	}
+	if err := write(encodedFilter); err != nil {
+		_ = file.Close()
+		return err
+	}
+	footer := Footer{
+		indexBlockOffset:  offset,
+		indexBlockSize:    uint32(len(encodedIndexBlock)),
+		filterBlockOffset: offset + uint32(len(encodedIndexBlock)),
+		filterBlockSize:   uint32(len(encodedFilter)),
+		maxVersion:        builder.maxVersion,
+		formatVersion:     formatVersion,
+	}
+	if err := write(footer.encode()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// hasRoomFor returns true if the current block can accommodate the key/value pair along with the block meta.
+// An empty block always has the room, even if the key/value pair is larger than the size of the block (the block is resized in allocate).
+// The key (without the version) is assumed to share nothing with the previous key, and the entry is assumed to be a restart point.
+func (builder *TableBuilder) hasRoomFor(key []byte, value []byte) bool {
+	currentBlock := builder.currentBlock
+	if len(currentBlock.restartOffsets) == 0 {
+		return true
+	}
+	entryHeader := newEntryHeader(0, key, value)
+	entrySize := len(entryHeader.encode()) + entryHeader.entrySize()
+	blockMetaSize := (len(currentBlock.restartOffsets)+1)*uint32Size + uint32Size
+
+	return currentBlock.endOffset+entrySize+blockMetaSize <= int(builder.options.SSTableBlockSizeInBytes)
+}
+
+// isRestartPoint returns true if the next entry of the current block is a restart point: the first entry of the block,
+// or the entry after BlockRestartInterval entries since the last restart point.
+func (builder *TableBuilder) isRestartPoint() bool {
+	return len(builder.currentBlock.restartOffsets) == 0 || builder.entriesSinceRestart >= builder.options.BlockRestartInterval
+}
+
+// finishBlock appends the block meta (the offsets of the restart points and their number) to the current block.
 func (builder *TableBuilder) finishBlock() {
-	builder.append(utils.U32SliceToBytes(builder.currentBlock.entryBeginOffsets))
-	builder.append(utils.U32ToBytesLittleEndian(uint32(len(builder.currentBlock.entryBeginOffsets))))
+	builder.append(utils.U32SliceToBytesLittleEndian(builder.currentBlock.restartOffsets))
+	builder.append(utils.U32ToBytesLittleEndian(uint32(len(builder.currentBlock.restartOffsets))))
 }
 
 func (builder *TableBuilder) append(part []byte) {
@@ -75,36 +241,112 @@ func (builder *TableBuilder) append(part []byte) {
 	copy(destination, part)
 }
 
+// allocate returns the next space bytes of the current block. The block grows past SSTableBlockSizeInBytes if needed
+// (for an entry that is larger than the block size), doubling its buffer so that a large entry is not copied repeatedly.
 func (builder *TableBuilder) allocate(space int) []byte {
-	//TODO: Does allocate need resizing?
 	currentBlock := builder.currentBlock
+	if currentBlock.endOffset+space > len(currentBlock.buffer) {
+		resizedLength := 2 * len(currentBlock.buffer)
+		if resizedLength < currentBlock.endOffset+space {
+			resizedLength = currentBlock.endOffset + space
+		}
+		resized := make([]byte, resizedLength)
+		copy(resized, currentBlock.buffer[:currentBlock.endOffset])
+		currentBlock.buffer = resized
+	}
 	currentBlock.endOffset = currentBlock.endOffset + space
 	return currentBlock.buffer[currentBlock.endOffset-space : currentBlock.endOffset]
 }
 
 func (builder *TableBuilder) newBlock() *Block {
+	builder.entriesSinceRestart = 0
 	return &Block{
-		buffer:            make([]byte, builder.options.SSTableBlockSizeInBytes),
-		entryBeginOffsets: []uint32{},
+		buffer:         make([]byte, builder.options.SSTableBlockSizeInBytes),
+		restartOffsets: []uint32{},
 	}
 }
 
-func newEntryHeader(key []byte, value []byte) *EntryHeader {
+// decodeBlock decodes the Block from the byte slice which contains the entries followed by the block meta.
+// It returns errors.CorruptDataBlockErr if the block meta does not fit in the byte slice, or if a restart offset points
+// outside the entries.
+func decodeBlock(buffer []byte) (*Block, error) {
+	if len(buffer) < uint32Size {
+		return nil, errors.CorruptDataBlockErr
+	}
+	totalRestarts := uint64(binary.LittleEndian.Uint32(buffer[len(buffer)-uint32Size:]))
+	if totalRestarts > uint64((len(buffer)-uint32Size)/uint32Size) {
+		return nil, errors.CorruptDataBlockErr
+	}
+	restartOffsetsStart := len(buffer) - uint32Size - int(totalRestarts)*uint32Size
+
+	restartOffsets := utils.BytesToU32SliceLittleEndian(buffer[restartOffsetsStart : len(buffer)-uint32Size])
+	for _, restartOffset := range restartOffsets {
+		if int(restartOffset) >= restartOffsetsStart {
+			return nil, errors.CorruptDataBlockErr
+		}
+	}
+	return &Block{
+		buffer:         buffer,
+		restartOffsets: restartOffsets,
+		dataEndOffset:  restartOffsetsStart,
+		endOffset:      len(buffer),
+	}, nil
+}
+
+// sizeInBytes returns the size of the decoded block in memory, which is accounted in the BlockCache.
+func (block *Block) sizeInBytes() uint64 {
+	return uint64(len(block.buffer) + len(block.restartOffsets)*uint32Size)
+}
+
+// sharedPrefixSize returns the size of the longest common prefix of the two keys.
+func sharedPrefixSize(key []byte, otherKey []byte) int {
+	size := 0
+	for size < len(key) && size < len(otherKey) && key[size] == otherKey[size] {
+		size++
+	}
+	return size
+}
+
+// TableFilePath returns the path of the SSTable file identified by the fileId in the directory.
+func TableFilePath(fileId uint64, directory string) string {
+	return filepath.Join(directory, fmt.Sprintf("%v.sst", fileId))
+}
+
+func newEntryHeader(sharedKeySize int, unsharedKey []byte, value []byte) *EntryHeader {
 	return &EntryHeader{
-		entrySize: uint16(len(key)) + uint16(len(value)) + entryHeaderSize,
-		keySize:   uint16(len(key)),
+		sharedKeySize:   uint32(sharedKeySize),
+		unsharedKeySize: uint32(len(unsharedKey)),
+		valueSize:       uint32(len(value)),
 	}
 }
 
+// entrySize returns the size of the entry, excluding the EntryHeader and including the version.
+func (entryHeader EntryHeader) entrySize() int {
+	return uint64Size + int(entryHeader.unsharedKeySize) + int(entryHeader.valueSize)
+}
+
 func (entryHeader EntryHeader) encode() []byte {
-	bytes := make([]byte, entryHeaderSize)
-	binary.LittleEndian.PutUint16(bytes, entryHeader.entrySize)
-	binary.LittleEndian.PutUint16(bytes[uint16Size:], entryHeader.keySize)
+	bytes := make([]byte, 0, maxEntryHeaderSize)
+	bytes = binary.AppendUvarint(bytes, uint64(entryHeader.sharedKeySize))
+	bytes = binary.AppendUvarint(bytes, uint64(entryHeader.unsharedKeySize))
+	bytes = binary.AppendUvarint(bytes, uint64(entryHeader.valueSize))
 
 	return bytes
 }
 
-func (entryHeader *EntryHeader) decodeFrom(part []byte) {
-	entryHeader.entrySize = binary.LittleEndian.Uint16(part)
-	entryHeader.keySize = binary.LittleEndian.Uint16(part[uint16Size:])
+// decodeFrom decodes the EntryHeader from the beginning of the part, and returns the size of the encoded EntryHeader.
+// It returns errors.CorruptDataBlockErr if the part does not begin with a valid EntryHeader.
+func (entryHeader *EntryHeader) decodeFrom(part []byte) (int, error) {
+	var sizes [3]uint32
+	headerSize := 0
+	for index := range sizes {
+		size, bytesRead := binary.Uvarint(part[headerSize:])
+		if bytesRead <= 0 || size > math.MaxUint32 {
+			return 0, errors.CorruptDataBlockErr
+		}
+		sizes[index] = uint32(size)
+		headerSize = headerSize + bytesRead
+	}
+	entryHeader.sharedKeySize, entryHeader.unsharedKeySize, entryHeader.valueSize = sizes[0], sizes[1], sizes[2]
+	return headerSize, nil
 }
@@ -1,10 +1,14 @@
 package sstable
 
 import (
+	"encoding/binary"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 	"tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable/errors"
 )
 
 func TestExistingKeyInAnSSTableBlock(t *testing.T) {
@@ -50,3 +54,119 @@ func TestAKeyGreaterOrEqualToTheOneBeingLookedForInAnSSTableBlock(t *testing.T)
 	assert.Equal(t, "SSD", blockIterator.key.AsString())
 	assert.Equal(t, "Solid state drive", string(blockIterator.value.ValueSlice()))
 }
+
+func TestAddsKeysAcrossMultipleBlocks(t *testing.T) {
+	options := option.DefaultOptions()
+	options.SSTableBlockSizeInBytes = 80
+
+	builder := NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 3), mvcc.NewValue([]byte("Hard disk drive")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+	builder.Add(mvcc.NewVersionedKey([]byte("Versioning"), 1), mvcc.NewValue([]byte("Semantic")))
+
+	builder.finishBlock()
+
+	assert.Equal(t, 1, len(builder.finishedBlocks))
+
+	blockIterator := NewBlockIterator(builder.finishedBlocks[0])
+	blockIterator.SeekToFirst()
+	assert.Equal(t, "HDD", blockIterator.key.AsString())
+	assert.Equal(t, "Hard disk", string(blockIterator.value.ValueSlice()))
+
+	blockIterator = NewBlockIterator(builder.currentBlock)
+	blockIterator.SeekToFirst()
+	assert.Equal(t, "SSD", blockIterator.key.AsString())
+	assert.Equal(t, "Solid state drive", string(blockIterator.value.ValueSlice()))
+}
+
+func TestIteratesOverAllTheKeysInAnSSTableBlock(t *testing.T) {
+	builder := NewSSTableBuilder(option.DefaultOptions())
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+	builder.finishBlock()
+
+	block, _ := decodeBlock(builder.currentBlock.buffer[:builder.currentBlock.endOffset])
+	blockIterator := NewBlockIterator(block)
+	blockIterator.SeekToFirst()
+	assert.True(t, blockIterator.IsValid())
+	assert.Equal(t, "HDD", blockIterator.Key().AsString())
+
+	blockIterator.Next()
+	assert.True(t, blockIterator.IsValid())
+	assert.Equal(t, "SSD", blockIterator.Key().AsString())
+
+	blockIterator.Next()
+	assert.False(t, blockIterator.IsValid())
+}
+
+func TestSeeksToKeysInAnSSTableBlockWithPrefixCompressedKeys(t *testing.T) {
+	builder := NewSSTableBuilder(option.DefaultOptions().SetBlockRestartInterval(3))
+	for keyIndex := 0; keyIndex < 20; keyIndex++ {
+		for version := uint64(1); version <= 3; version++ {
+			key := mvcc.NewVersionedKey([]byte(fmt.Sprintf("accounts/%03d", keyIndex)), version)
+			builder.Add(key, mvcc.NewValue([]byte(fmt.Sprintf("balance-%d-%d", keyIndex, version))))
+		}
+	}
+	builder.finishBlock()
+
+	block, err := decodeBlock(builder.currentBlock.buffer[:builder.currentBlock.endOffset])
+	assert.Nil(t, err)
+	assert.Equal(t, 20, len(block.restartOffsets))
+
+	for keyIndex := 0; keyIndex < 20; keyIndex++ {
+		for version := uint64(1); version <= 3; version++ {
+			blockIterator := NewBlockIterator(block)
+			blockIterator.Seek(mvcc.NewVersionedKey([]byte(fmt.Sprintf("accounts/%03d", keyIndex)), version))
+
+			assert.True(t, blockIterator.IsValid())
+			assert.Equal(t, fmt.Sprintf("accounts/%03d", keyIndex), blockIterator.Key().AsString())
+			assert.Equal(t, version, blockIterator.Key().Version)
+			assert.Equal(t, fmt.Sprintf("balance-%d-%d", keyIndex, version), string(blockIterator.Value().ValueSlice()))
+		}
+	}
+}
+
+func TestPrefixCompressionReducesTheSizeOfAnSSTableBlock(t *testing.T) {
+	build := func(restartInterval int) *Block {
+		builder := NewSSTableBuilder(option.DefaultOptions().SetBlockRestartInterval(restartInterval))
+		for keyIndex := 0; keyIndex < 50; keyIndex++ {
+			key := mvcc.NewVersionedKey([]byte(fmt.Sprintf("users/profiles/%03d", keyIndex)), 1)
+			builder.Add(key, mvcc.NewValue([]byte("profile")))
+		}
+		builder.finishBlock()
+		return builder.currentBlock
+	}
+	uncompressed, compressed := build(1), build(16)
+
+	assert.Less(t, compressed.endOffset, uncompressed.endOffset)
+
+	block, _ := decodeBlock(compressed.buffer[:compressed.endOffset])
+	blockIterator := NewBlockIterator(block)
+	blockIterator.SeekToFirst()
+	for keyIndex := 0; keyIndex < 50; keyIndex++ {
+		assert.True(t, blockIterator.IsValid())
+		assert.Equal(t, fmt.Sprintf("users/profiles/%03d", keyIndex), blockIterator.Key().AsString())
+		blockIterator.Next()
+	}
+	assert.False(t, blockIterator.IsValid())
+}
+
+func TestDecodesACorruptSSTableBlock(t *testing.T) {
+	builder := NewSSTableBuilder(option.DefaultOptions())
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.finishBlock()
+	buffer := append([]byte(nil), builder.currentBlock.buffer[:builder.currentBlock.endOffset]...)
+
+	_, err := decodeBlock(buffer[:2])
+	assert.Equal(t, errors.CorruptDataBlockErr, err)
+
+	binary.LittleEndian.PutUint32(buffer[len(buffer)-uint32Size:], math.MaxUint32)
+	_, err = decodeBlock(buffer)
+	assert.Equal(t, errors.CorruptDataBlockErr, err)
+
+	binary.LittleEndian.PutUint32(buffer[len(buffer)-uint32Size:], 1)
+	binary.LittleEndian.PutUint32(buffer[len(buffer)-2*uint32Size:], uint32(len(buffer)))
+	_, err = decodeBlock(buffer)
+	assert.Equal(t, errors.CorruptDataBlockErr, err)
+}
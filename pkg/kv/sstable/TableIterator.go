@@ -0,0 +1,115 @@
+package sstable
+
+import (
+	"io"
+	"tinydb/pkg/kv/mvcc"
+)
+
+// TableIterator allows forward movement across all the blocks of an SSTable.
+// It uses the IndexBlock of the TableReader to find the block to start from, and moves to the next block when the
+// current block is exhausted. The IndexBlock is loaded once (it may come from the BlockCache) and kept for the lifetime
+// of the TableIterator.
+type TableIterator struct {
+	reader        *TableReader
+	indexBlock    *IndexBlock
+	blockIndex    int
+	blockIterator *BlockIterator
+	err           error
+}
+
+// Seek positions the TableIterator at the first entry whose key is greater than or equal to the incoming key.
+func (iterator *TableIterator) Seek(key mvcc.VersionedKey) {
+	indexBlock, err := iterator.loadIndexBlock()
+	if err != nil {
+		iterator.err = err
+		return
+	}
+	if !iterator.loadBlock(indexBlock.blockIndexFor(key)) {
+		return
+	}
+	iterator.blockIterator.Seek(key)
+	if !iterator.blockIterator.IsValid() {
+		iterator.moveToNextBlock()
+	}
+}
+
+// SeekToFirst positions the TableIterator at the first entry of the SSTable.
+func (iterator *TableIterator) SeekToFirst() {
+	if !iterator.loadBlock(0) {
+		return
+	}
+	iterator.blockIterator.SeekToFirst()
+}
+
+// Next moves the TableIterator to the next entry. It is ESSENTIAL to call IsValid() before calling Next.
+func (iterator *TableIterator) Next() {
+	iterator.blockIterator.Next()
+	if !iterator.blockIterator.IsValid() {
+		iterator.moveToNextBlock()
+	}
+}
+
+// IsValid returns true if the TableIterator is positioned at an entry, false otherwise.
+func (iterator *TableIterator) IsValid() bool {
+	return iterator.err == nil && iterator.blockIterator != nil && iterator.blockIterator.IsValid()
+}
+
+// Key returns the mvcc.VersionedKey of the entry the TableIterator is positioned at.
+func (iterator *TableIterator) Key() mvcc.VersionedKey {
+	return iterator.blockIterator.Key()
+}
+
+// Value returns the mvcc.Value of the entry the TableIterator is positioned at.
+func (iterator *TableIterator) Value() mvcc.Value {
+	return iterator.blockIterator.Value()
+}
+
+// Err returns the error (if any) that was encountered while reading a block. io.EOF is not considered an error.
+func (iterator *TableIterator) Err() error {
+	if iterator.err == io.EOF {
+		return nil
+	}
+	return iterator.err
+}
+
+// moveToNextBlock positions the TableIterator at the first entry of the next block.
+func (iterator *TableIterator) moveToNextBlock() {
+	if iterator.loadBlock(iterator.blockIndex + 1) {
+		iterator.blockIterator.SeekToFirst()
+	}
+}
+
+// loadBlock reads the block at the blockIndex and creates a BlockIterator over it.
+// It returns false if there is no block at the blockIndex or if the block can not be read.
+func (iterator *TableIterator) loadBlock(blockIndex int) bool {
+	indexBlock, err := iterator.loadIndexBlock()
+	if err != nil {
+		iterator.err = err
+		return false
+	}
+	if blockIndex >= indexBlock.totalBlocks() {
+		iterator.err = io.EOF
+		return false
+	}
+	block, err := iterator.reader.readBlock(indexBlock, blockIndex)
+	if err != nil {
+		iterator.err = err
+		return false
+	}
+	iterator.blockIndex = blockIndex
+	iterator.blockIterator = NewBlockIterator(block)
+	iterator.err = nil
+	return true
+}
+
+// loadIndexBlock returns the IndexBlock of the SSTable, loading it from the TableReader on the first call.
+func (iterator *TableIterator) loadIndexBlock() (*IndexBlock, error) {
+	if iterator.indexBlock == nil {
+		indexBlock, err := iterator.reader.loadIndexBlock()
+		if err != nil {
+			return nil, err
+		}
+		iterator.indexBlock = indexBlock
+	}
+	return iterator.indexBlock, nil
+}
@@ -0,0 +1,341 @@
+package sstable
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable/errors"
+)
+
+// TableReader reads an SSTable file that is built by the TableBuilder.
+// TableReader reads the Footer, the IndexBlock and the BloomFilter when it is created, keeps the file open and reads the data blocks on demand.
+// TableReader also keeps the smallest and the largest key of the SSTable, which are used to find the SSTables that overlap
+// a key range during compaction.
+// TableReader is reference counted: the kv.Workspace holds one reference, and every reader of the kv.Workspace holds one
+// while it is reading. The file is closed when the last reference is released, and it is removed if the SSTable was
+// compacted away (refer to RemoveOnRelease).
+// With a BlockCache, the TableReader does not hold the IndexBlock and the BloomFilter: they are cached (and pinned, if
+// pinIndexAndFilter is set) in the BlockCache along with the data blocks, and read again from the file if evicted.
+// Without a BlockCache, the TableReader holds the IndexBlock and the BloomFilter, and reads the data blocks from the file every time.
+type TableReader struct {
+	fileId            uint64
+	filePath          string
+	file              *os.File
+	footer            *Footer
+	indexBlock        *IndexBlock
+	filter            *BloomFilter
+	blockCache        *BlockCache
+	pinIndexAndFilter bool
+	maxVersion        uint64
+	minKey            []byte
+	maxKey            []byte
+	sizeInBytes       uint64
+	references        atomic.Int64
+	removeOnRelease   atomic.Bool
+}
+
+// NewTableReader creates a new instance of TableReader for the SSTable file identified by the fileId in the DbDirectory.
+// It returns an error if the file does not end with a valid Footer or if the IndexBlock can not be decoded.
+// The TableReader created by NewTableReader does not use a BlockCache (refer to NewTableReaderWithCache).
+func NewTableReader(fileId uint64, options *option.Options) (*TableReader, error) {
+	return NewTableReaderWithCache(fileId, options, nil, false)
+}
+
+// NewTableReaderWithCache creates a new instance of TableReader that reads the blocks through the blockCache.
+// If pinIndexAndFilter is set, the IndexBlock and the BloomFilter are pinned in the blockCache till the SSTable file is closed.
+func NewTableReaderWithCache(fileId uint64, options *option.Options, blockCache *BlockCache, pinIndexAndFilter bool) (*TableReader, error) {
+	filePath := TableFilePath(fileId, options.DbDirectory)
+	file, err := os.OpenFile(filePath, os.O_RDONLY, 0444)
+	if err != nil {
+		return nil, err
+	}
+	reader := &TableReader{
+		fileId:            fileId,
+		filePath:          filePath,
+		file:              file,
+		blockCache:        blockCache,
+		pinIndexAndFilter: pinIndexAndFilter,
+	}
+	if err := reader.readIndexBlockAndFilter(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err := reader.readKeyRange(); err != nil {
+		reader.removeIndexBlockAndFilterFromCache()
+		_ = file.Close()
+		return nil, err
+	}
+	reader.references.Store(1)
+	return reader, nil
+}
+
+// MayContain returns false if the BloomFilter of the SSTable says that the key (without the version) is definitely absent.
+// It does not read any data block, so it should be checked before Get.
+// It returns true if the BloomFilter can not be read, so the SSTable is searched.
+func (reader *TableReader) MayContain(key []byte) bool {
+	filter, err := reader.loadFilter()
+	if err != nil {
+		return true
+	}
+	return filter.MayContain(key)
+}
+
+// Get returns a pair of (ValueWithVersion, bool) for the incoming key.
+// It returns (ValueWithVersion, true) for the latest version of the key that is less than or equal to the version of
+// the incoming key, else (nil, false).
+// Unlike the Get of mvcc.MemTable, Get returns the deleted value as well. It is the responsibility of the caller to check
+// if the value is deleted.
+// Get seeks to the first version of the key and moves forward till it finds a different key or a greater version.
+// The versions of a key can span across the blocks, and the TableIterator moves across the blocks.
+func (reader *TableReader) Get(key mvcc.VersionedKey) (mvcc.ValueWithVersion, bool) {
+	valueWithVersion, found := mvcc.EmptyValueWithZeroVersion(), false
+
+	iterator := reader.NewIterator()
+	for iterator.Seek(mvcc.NewVersionedKey(key.KeySlice(), 0)); iterator.IsValid(); iterator.Next() {
+		existingKey := iterator.Key()
+		if !bytes.Equal(existingKey.KeySlice(), key.KeySlice()) || existingKey.Version > key.Version {
+			break
+		}
+		valueWithVersion, found = mvcc.NewValueWithVersion(iterator.Value(), existingKey.Version), true
+	}
+	return valueWithVersion, found
+}
+
+// NewIterator creates a new TableIterator over all the key/value pairs of the SSTable.
+func (reader *TableReader) NewIterator() *TableIterator {
+	return &TableIterator{reader: reader}
+}
+
+// FileId returns the id of the SSTable file.
+func (reader *TableReader) FileId() uint64 {
+	return reader.fileId
+}
+
+// MaxVersion returns the largest commitTimestamp of all the keys present in the SSTable.
+func (reader *TableReader) MaxVersion() uint64 {
+	return reader.maxVersion
+}
+
+// MinKey returns the smallest key (without the version) present in the SSTable.
+func (reader *TableReader) MinKey() []byte {
+	return reader.minKey
+}
+
+// MaxKey returns the largest key (without the version) present in the SSTable.
+func (reader *TableReader) MaxKey() []byte {
+	return reader.maxKey
+}
+
+// SizeInBytes returns the size of the SSTable file.
+func (reader *TableReader) SizeInBytes() uint64 {
+	return reader.sizeInBytes
+}
+
+// ContainsKey returns true if the key falls in the range [MinKey, MaxKey] of the SSTable.
+// It does not mean that the key is present in the SSTable.
+func (reader *TableReader) ContainsKey(key []byte) bool {
+	return bytes.Compare(key, reader.minKey) >= 0 && bytes.Compare(key, reader.maxKey) <= 0
+}
+
+// Overlaps returns true if the range [minKey, maxKey] overlaps the range [MinKey, MaxKey] of the SSTable.
+func (reader *TableReader) Overlaps(minKey []byte, maxKey []byte) bool {
+	return bytes.Compare(minKey, reader.maxKey) <= 0 && bytes.Compare(maxKey, reader.minKey) >= 0
+}
+
+// IncrementReference acquires a reference on the TableReader.
+func (reader *TableReader) IncrementReference() {
+	reader.references.Add(1)
+}
+
+// DecrementReference releases a reference on the TableReader.
+// The SSTable file is closed when the last reference is released, and removed if RemoveOnRelease was called.
+func (reader *TableReader) DecrementReference() error {
+	if reader.references.Add(-1) > 0 {
+		return nil
+	}
+	reader.removeIndexBlockAndFilterFromCache()
+	if err := reader.file.Close(); err != nil {
+		return err
+	}
+	if reader.removeOnRelease.Load() {
+		return os.Remove(reader.filePath)
+	}
+	return nil
+}
+
+// RemoveOnRelease marks the SSTable file to be removed when the last reference on the TableReader is released.
+func (reader *TableReader) RemoveOnRelease() {
+	reader.removeOnRelease.Store(true)
+}
+
+// Close closes the SSTable file.
+func (reader *TableReader) Close() error {
+	reader.removeIndexBlockAndFilterFromCache()
+	return reader.file.Close()
+}
+
+// readIndexBlockAndFilter reads the Footer from the end of the file, followed by the IndexBlock and the BloomFilter.
+// The IndexBlock and the BloomFilter are cached in the BlockCache, or held by the TableReader if there is no BlockCache.
+func (reader *TableReader) readIndexBlockAndFilter() error {
+	stat, err := reader.file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() < int64(footerSize) {
+		return errors.InvalidMagicNumberErr
+	}
+	footerBytes := make([]byte, footerSize)
+	if _, err := reader.file.ReadAt(footerBytes, stat.Size()-int64(footerSize)); err != nil {
+		return err
+	}
+	footer := new(Footer)
+	if err := footer.decodeFrom(footerBytes); err != nil {
+		return err
+	}
+	if int64(footer.indexBlockOffset)+int64(footer.indexBlockSize) > stat.Size()-int64(footerSize) {
+		return errors.CorruptIndexBlockErr
+	}
+	if int64(footer.filterBlockOffset)+int64(footer.filterBlockSize) > stat.Size()-int64(footerSize) {
+		return errors.CorruptFilterBlockErr
+	}
+	reader.footer = footer
+	indexBlock, err := reader.readIndexBlock()
+	if err != nil {
+		return err
+	}
+	filter, err := reader.readFilter()
+	if err != nil {
+		return err
+	}
+	if reader.blockCache == nil {
+		reader.indexBlock, reader.filter = indexBlock, filter
+	} else {
+		reader.cacheIndexBlockOrFilter(footer.indexBlockOffset, indexBlock, uint64(footer.indexBlockSize))
+		reader.cacheIndexBlockOrFilter(footer.filterBlockOffset, filter, uint64(footer.filterBlockSize))
+	}
+	reader.maxVersion = footer.maxVersion
+	reader.sizeInBytes = uint64(stat.Size())
+	return nil
+}
+
+// readIndexBlock reads the IndexBlock from the SSTable file.
+func (reader *TableReader) readIndexBlock() (*IndexBlock, error) {
+	indexBlockBytes := make([]byte, reader.footer.indexBlockSize)
+	if _, err := reader.file.ReadAt(indexBlockBytes, int64(reader.footer.indexBlockOffset)); err != nil {
+		return nil, err
+	}
+	return decodeIndexBlock(indexBlockBytes)
+}
+
+// readFilter reads the BloomFilter from the SSTable file.
+func (reader *TableReader) readFilter() (*BloomFilter, error) {
+	filterBlockBytes := make([]byte, reader.footer.filterBlockSize)
+	if _, err := reader.file.ReadAt(filterBlockBytes, int64(reader.footer.filterBlockOffset)); err != nil {
+		return nil, err
+	}
+	return decodeBloomFilter(filterBlockBytes), nil
+}
+
+// loadIndexBlock returns the IndexBlock held by the TableReader, else the IndexBlock from the BlockCache, else reads
+// the IndexBlock from the SSTable file and caches it.
+func (reader *TableReader) loadIndexBlock() (*IndexBlock, error) {
+	if reader.indexBlock != nil {
+		return reader.indexBlock, nil
+	}
+	if block, ok := reader.blockCache.get(reader.fileId, reader.footer.indexBlockOffset); ok {
+		return block.(*IndexBlock), nil
+	}
+	indexBlock, err := reader.readIndexBlock()
+	if err != nil {
+		return nil, err
+	}
+	reader.cacheIndexBlockOrFilter(reader.footer.indexBlockOffset, indexBlock, uint64(reader.footer.indexBlockSize))
+	return indexBlock, nil
+}
+
+// loadFilter returns the BloomFilter held by the TableReader, else the BloomFilter from the BlockCache, else reads
+// the BloomFilter from the SSTable file and caches it.
+func (reader *TableReader) loadFilter() (*BloomFilter, error) {
+	if reader.filter != nil {
+		return reader.filter, nil
+	}
+	if block, ok := reader.blockCache.get(reader.fileId, reader.footer.filterBlockOffset); ok {
+		return block.(*BloomFilter), nil
+	}
+	filter, err := reader.readFilter()
+	if err != nil {
+		return nil, err
+	}
+	reader.cacheIndexBlockOrFilter(reader.footer.filterBlockOffset, filter, uint64(reader.footer.filterBlockSize))
+	return filter, nil
+}
+
+// cacheIndexBlockOrFilter caches the IndexBlock or the BloomFilter at the blockOffset, pinning it if pinIndexAndFilter is set.
+func (reader *TableReader) cacheIndexBlockOrFilter(blockOffset uint32, block any, sizeInBytes uint64) {
+	if reader.pinIndexAndFilter {
+		reader.blockCache.pin(reader.fileId, blockOffset, block, sizeInBytes)
+		return
+	}
+	reader.blockCache.put(reader.fileId, blockOffset, block, sizeInBytes)
+}
+
+// removeIndexBlockAndFilterFromCache removes the IndexBlock and the BloomFilter (pinned or not) from the BlockCache,
+// when the SSTable file is closed. The data blocks are not removed, they are evicted eventually.
+func (reader *TableReader) removeIndexBlockAndFilterFromCache() {
+	if reader.footer == nil {
+		return
+	}
+	reader.blockCache.remove(reader.fileId, reader.footer.indexBlockOffset)
+	reader.blockCache.remove(reader.fileId, reader.footer.filterBlockOffset)
+}
+
+// readKeyRange reads the smallest key from the IndexBlock and the largest key from the last block of the SSTable.
+func (reader *TableReader) readKeyRange() error {
+	indexBlock, err := reader.loadIndexBlock()
+	if err != nil {
+		return err
+	}
+	if indexBlock.totalBlocks() == 0 {
+		return errors.CorruptIndexBlockErr
+	}
+	minKey := new(mvcc.VersionedKey)
+	minKey.DecodeFrom(indexBlock.entries[0].firstKey)
+
+	lastBlock, err := reader.readBlock(indexBlock, indexBlock.totalBlocks()-1)
+	if err != nil {
+		return err
+	}
+	blockIterator := NewBlockIterator(lastBlock)
+	blockIterator.seekToLast()
+	if !blockIterator.IsValid() {
+		return errors.CorruptIndexBlockErr
+	}
+	reader.minKey = minKey.KeySlice()
+	reader.maxKey = blockIterator.Key().KeySlice()
+	return nil
+}
+
+// readBlock returns the block at the blockIndex of the indexBlock from the BlockCache, else reads the block from the
+// SSTable file, decompresses it and caches it. The BlockCache holds the decompressed blocks.
+func (reader *TableReader) readBlock(indexBlock *IndexBlock, blockIndex int) (*Block, error) {
+	indexBlockEntry := indexBlock.entries[blockIndex]
+	if block, ok := reader.blockCache.get(reader.fileId, indexBlockEntry.blockOffset); ok {
+		return block.(*Block), nil
+	}
+	encodedBlock := make([]byte, indexBlockEntry.blockSize)
+	if _, err := reader.file.ReadAt(encodedBlock, int64(indexBlockEntry.blockOffset)); err != nil {
+		return nil, err
+	}
+	buffer, err := decodeDataBlock(encodedBlock)
+	if err != nil {
+		return nil, err
+	}
+	block, err := decodeBlock(buffer)
+	if err != nil {
+		return nil, err
+	}
+	reader.blockCache.put(reader.fileId, indexBlockEntry.blockOffset, block, block.sizeInBytes())
+	return block, nil
+}
@@ -0,0 +1,323 @@
+package sstable
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/sstable/errors"
+)
+
+func buildTable(t *testing.T, options *option.Options) *TableReader {
+	builder := NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 3), mvcc.NewValue([]byte("Hard disk drive")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 2), mvcc.NewDeletedValue())
+	builder.Add(mvcc.NewVersionedKey([]byte("Versioning"), 1), mvcc.NewValue([]byte("Semantic")))
+
+	assert.Nil(t, builder.Build(1))
+
+	reader, err := NewTableReader(1, options)
+	assert.Nil(t, err)
+	return reader
+}
+
+func TestGetsTheValueOfAKeyFromAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(3), valueWithVersion.Version)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+}
+
+func TestGetsTheValueOfAKeyWithTheNearestVersionFromAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(1), valueWithVersion.Version)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+}
+
+func TestGetsADeletedValueFromAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey([]byte("SSD"), 5))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, valueWithVersion.IsDeleted())
+}
+
+func TestGetsANonExistingKeyFromAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	_, ok := reader.Get(mvcc.NewVersionedKey([]byte("Disk"), 5))
+	assert.Equal(t, false, ok)
+
+	_, ok = reader.Get(mvcc.NewVersionedKey([]byte("HDD"), 0))
+	assert.Equal(t, false, ok)
+}
+
+func TestGetsTheValuesOfKeysFromAnSSTableWithMultipleBlocks(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	options.SSTableBlockSizeInBytes = 48
+
+	reader := buildTable(t, options)
+	defer reader.Close()
+
+	assert.True(t, reader.indexBlock.totalBlocks() > 1)
+
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey([]byte("HDD"), 10))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok = reader.Get(mvcc.NewVersionedKey([]byte("Versioning"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Semantic", string(valueWithVersion.ValueSlice()))
+}
+
+func TestIteratesOverAllTheKeysOfAnSSTableWithMultipleBlocks(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	options.SSTableBlockSizeInBytes = 48
+
+	reader := buildTable(t, options)
+	defer reader.Close()
+
+	var keys []string
+	iterator := reader.NewIterator()
+	for iterator.SeekToFirst(); iterator.IsValid(); iterator.Next() {
+		keys = append(keys, iterator.Key().AsString())
+	}
+	assert.Nil(t, iterator.Err())
+	assert.Equal(t, []string{"HDD", "HDD", "SSD", "SSD", "Versioning"}, keys)
+}
+
+func TestSeeksToAKeyInAnSSTableWithMultipleBlocks(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	options.SSTableBlockSizeInBytes = 48
+
+	reader := buildTable(t, options)
+	defer reader.Close()
+
+	iterator := reader.NewIterator()
+	iterator.Seek(mvcc.NewVersionedKey([]byte("REQUEST"), 1))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, "SSD", iterator.Key().AsString())
+	assert.Equal(t, uint64(1), iterator.Key().Version)
+
+	iterator.Seek(mvcc.NewVersionedKey([]byte("ZERO"), 1))
+	assert.False(t, iterator.IsValid())
+}
+
+func TestFailsToOpenAFileWithoutTheMagicNumber(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	_ = os.WriteFile(TableFilePath(1, options.DbDirectory), []byte("not an sstable, just some bytes"), 0644)
+
+	_, err := NewTableReader(1, options)
+	assert.Error(t, err)
+	assert.Equal(t, errors.InvalidMagicNumberErr, err)
+}
+
+func TestFailsToOpenAFileWithAnUnsupportedFormatVersion(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	footer := Footer{formatVersion: formatVersion + 1}
+	_ = os.WriteFile(TableFilePath(1, options.DbDirectory), footer.encode(), 0644)
+
+	_, err := NewTableReader(1, options)
+	assert.Error(t, err)
+	assert.Equal(t, errors.UnsupportedFormatVersionErr, err)
+}
+
+func TestReadsTheMaxVersionOfAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	assert.Equal(t, uint64(3), reader.MaxVersion())
+}
+
+func TestReadsTheKeyRangeOfAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	assert.Equal(t, "HDD", string(reader.MinKey()))
+	assert.Equal(t, "Versioning", string(reader.MaxKey()))
+	assert.True(t, reader.ContainsKey([]byte("SSD")))
+	assert.False(t, reader.ContainsKey([]byte("Z")))
+	assert.True(t, reader.Overlaps([]byte("A"), []byte("HDD")))
+	assert.False(t, reader.Overlaps([]byte("W"), []byte("Z")))
+}
+
+func TestRemovesTheSSTableOnReleaseOfTheLastReference(t *testing.T) {
+	directory := t.TempDir()
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(directory))
+	reader.IncrementReference()
+	reader.RemoveOnRelease()
+
+	assert.Nil(t, reader.DecrementReference())
+	_, err := os.Stat(TableFilePath(1, directory))
+	assert.Nil(t, err)
+
+	assert.Nil(t, reader.DecrementReference())
+	_, err = os.Stat(TableFilePath(1, directory))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestChecksTheBloomFilterOfAnSSTable(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer reader.Close()
+
+	assert.True(t, reader.MayContain([]byte("HDD")))
+	assert.True(t, reader.MayContain([]byte("Versioning")))
+	assert.False(t, reader.MayContain([]byte("NVMe")))
+}
+
+func TestAnSSTableWithoutABloomFilterMayContainAnyKey(t *testing.T) {
+	reader := buildTable(t, option.DefaultOptions().SetDbDirectory(t.TempDir()).SetBloomFilterBitsPerKey(0))
+	defer reader.Close()
+
+	assert.True(t, reader.MayContain([]byte("NVMe")))
+}
+
+func TestReadsTheBlocksOfAnSSTableThroughTheBlockCache(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	builder := NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state drive")))
+	assert.Nil(t, builder.Build(1))
+
+	blockCache := NewBlockCache(1024 * 1024)
+	reader, err := NewTableReaderWithCache(1, options, blockCache, false)
+	assert.Nil(t, err)
+	assert.Nil(t, reader.indexBlock)
+	assert.Nil(t, reader.filter)
+
+	anotherReader, err := NewTableReaderWithCache(1, options, blockCache, false)
+	assert.Nil(t, err)
+
+	before := blockCache.Statistics()
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Solid state drive", string(valueWithVersion.ValueSlice()))
+
+	valueWithVersion, ok = anotherReader.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk", string(valueWithVersion.ValueSlice()))
+
+	after := blockCache.Statistics()
+	assert.Equal(t, before.Misses, after.Misses)
+	assert.True(t, after.Hits > before.Hits)
+
+	assert.Nil(t, reader.Close())
+	assert.Nil(t, anotherReader.Close())
+}
+
+func TestReadsTheEvictedIndexBlockAndFilterFromTheSSTable(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	reader := buildTable(t, options)
+	defer reader.Close()
+
+	blockCache := NewBlockCache(1024 * 1024)
+	readerWithCache, err := NewTableReaderWithCache(1, options, blockCache, false)
+	assert.Nil(t, err)
+	defer readerWithCache.Close()
+
+	blockCache.remove(1, readerWithCache.footer.indexBlockOffset)
+	blockCache.remove(1, readerWithCache.footer.filterBlockOffset)
+
+	assert.Equal(t, true, readerWithCache.MayContain([]byte("HDD")))
+	valueWithVersion, ok := readerWithCache.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Hard disk drive", string(valueWithVersion.ValueSlice()))
+
+	_, ok = blockCache.get(1, readerWithCache.footer.indexBlockOffset)
+	assert.Equal(t, true, ok)
+}
+
+func TestPinsTheIndexBlockAndFilterInTheBlockCacheTillTheSSTableIsClosed(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	reader := buildTable(t, options)
+	defer reader.Close()
+
+	blockCache := NewBlockCache(blockCacheShards)
+	pinnedReader, err := NewTableReaderWithCache(1, options, blockCache, true)
+	assert.Nil(t, err)
+
+	_, ok := blockCache.get(1, pinnedReader.footer.indexBlockOffset)
+	assert.Equal(t, true, ok)
+	_, ok = blockCache.get(1, pinnedReader.footer.filterBlockOffset)
+	assert.Equal(t, true, ok)
+
+	assert.Nil(t, pinnedReader.Close())
+
+	_, ok = blockCache.get(1, pinnedReader.footer.indexBlockOffset)
+	assert.Equal(t, false, ok)
+}
+
+func TestReadsAnSSTableWithCompressedBlocks(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetCompressionType(option.FlateCompression)
+	uncompressedOptions := option.DefaultOptions().SetDbDirectory(t.TempDir())
+
+	for _, tableOptions := range []*option.Options{options, uncompressedOptions} {
+		builder := NewSSTableBuilder(tableOptions)
+		for version := uint64(1); version <= 100; version++ {
+			builder.Add(mvcc.NewVersionedKey([]byte("disk"), version), mvcc.NewValue(repetitiveDocument[:200]))
+		}
+		assert.Nil(t, builder.Build(1))
+	}
+
+	reader, err := NewTableReader(1, options)
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	uncompressedReader, err := NewTableReader(1, uncompressedOptions)
+	assert.Nil(t, err)
+	defer uncompressedReader.Close()
+
+	assert.True(t, reader.SizeInBytes() < uncompressedReader.SizeInBytes()/2)
+
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey([]byte("disk"), 50))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(50), valueWithVersion.Version)
+	assert.Equal(t, repetitiveDocument[:200], valueWithVersion.ValueSlice())
+
+	total, iterator := 0, reader.NewIterator()
+	for iterator.SeekToFirst(); iterator.IsValid(); iterator.Next() {
+		total++
+	}
+	assert.Equal(t, 100, total)
+}
+
+func TestReadsAnSSTableWithKeysAndValuesLargerThanTheBlockSize(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	largeKey, largeValue := bytes.Repeat([]byte("K"), 70*1024), bytes.Repeat([]byte("v"), 100*1024)
+
+	builder := NewSSTableBuilder(options)
+	builder.Add(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	builder.Add(mvcc.NewVersionedKey(largeKey, 1), mvcc.NewValue(largeValue))
+	builder.Add(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue(largeValue))
+	builder.Add(mvcc.NewVersionedKey([]byte("Versioning"), 1), mvcc.NewValue([]byte("Semantic")))
+	assert.Nil(t, builder.Build(1))
+
+	reader, err := NewTableReader(1, options)
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	valueWithVersion, ok := reader.Get(mvcc.NewVersionedKey(largeKey, 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, largeValue, valueWithVersion.ValueSlice())
+
+	valueWithVersion, ok = reader.Get(mvcc.NewVersionedKey([]byte("SSD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, largeValue, valueWithVersion.ValueSlice())
+
+	valueWithVersion, ok = reader.Get(mvcc.NewVersionedKey([]byte("Versioning"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Semantic", string(valueWithVersion.ValueSlice()))
+}
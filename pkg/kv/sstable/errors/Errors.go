@@ -0,0 +1,10 @@
+package errors
+
+import "errors"
+
+var InvalidMagicNumberErr = errors.New("sstable does not end with the magic number, it is either corrupt or not an sstable")
+var UnsupportedFormatVersionErr = errors.New("sstable is written in a format version that is not supported")
+var CorruptIndexBlockErr = errors.New("sstable has a corrupt index block")
+var CorruptFilterBlockErr = errors.New("sstable has a corrupt filter block")
+var CorruptDataBlockErr = errors.New("sstable has a corrupt data block")
+var UnsupportedCompressionTypeErr = errors.New("sstable data block is compressed with a compression type that does not have a codec")
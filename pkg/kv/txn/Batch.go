@@ -1,14 +1,15 @@
 package txn
 
 import (
-	"bytes"
 	"tinydb/pkg/kv/txn/errors"
 )
 
 // KeyValuePair wraps a key and a value.
+// A KeyValuePair with the deleted flag is a tombstone marker: the key is deleted by the transaction and there is no value.
 type KeyValuePair struct {
-	key   []byte
-	value []byte
+	key     []byte
+	value   []byte
+	deleted bool
 }
 
 func newKeyValuePair(key, value []byte) KeyValuePair {
@@ -18,6 +19,13 @@ func newKeyValuePair(key, value []byte) KeyValuePair {
 	}
 }
 
+func newDeletedKeyValuePair(key []byte) KeyValuePair {
+	return KeyValuePair{
+		key:     key,
+		deleted: true,
+	}
+}
+
 func (pair KeyValuePair) getKey() []byte {
 	return pair.key
 }
@@ -26,10 +34,24 @@ func (pair KeyValuePair) getValue() []byte {
 	return pair.value
 }
 
+func (pair KeyValuePair) isDeleted() bool {
+	return pair.deleted
+}
+
 // Batch maintains all the key/value pairs that are a part of one RW-transaction.
 // Every ReadWriteTransaction will batch the changes and when the changes are ready to be committed, the Commit() method will be invoked.
+// The pairs are kept in the order they are added, and are indexed by key, so that Add, Get and Contains do not scan the pairs.
+// A key that is added again replaces the earlier key/value pair (last write wins), unless the Batch rejects duplicate keys
+// (refer to SetRejectDuplicateKeys).
+// A Batch may be bounded by the maximum number of entries and the maximum size (of keys and values) in bytes, so that a single
+// transaction can not overflow a memtable when it is applied.
 type Batch struct {
-	pairs []KeyValuePair
+	pairs               []KeyValuePair
+	index               map[string]int
+	sizeInBytes         uint64
+	maxEntries          int
+	maxSizeInBytes      uint64
+	rejectDuplicateKeys bool
 }
 
 // TimestampedBatch represents the Batch which is given the commit timestamp.
@@ -38,57 +60,155 @@ type Batch struct {
 type TimestampedBatch struct {
 	batch          *Batch
 	timestamp      uint64
-	doneChannel    chan struct{}
+	doneChannel    chan error
 	commitCallback func()
 }
 
 // NewBatch creates a new instance of Batch.
 // In the current implementation a new instance of Batch is created for every ReadWriteTransaction.
 // This is a good opportunity to use object-pool pattern.
+// The Batch created by NewBatch is not bounded (refer to NewBoundedBatch).
 func NewBatch() *Batch {
-	return &Batch{}
+	return NewBoundedBatch(0, 0)
+}
+
+// NewBoundedBatch creates a new instance of Batch that can contain at most maxEntries key/value pairs, and at most
+// maxSizeInBytes of keys and values. A limit of 0 means that the Batch is not bounded by that limit.
+func NewBoundedBatch(maxEntries int, maxSizeInBytes uint64) *Batch {
+	return &Batch{
+		index:          make(map[string]int),
+		maxEntries:     maxEntries,
+		maxSizeInBytes: maxSizeInBytes,
+	}
+}
+
+// SetRejectDuplicateKeys makes the Batch return errors.DuplicateKeyInBatchErr when a key that is already present is added again.
+func (batch *Batch) SetRejectDuplicateKeys(reject bool) *Batch {
+	batch.rejectDuplicateKeys = reject
+	return batch
 }
 
-// Add adds the key/value pair in the Batch. Throws an error if the key is already present in the Batch.
+// Add adds the key/value pair in the Batch. If the key is already present in the Batch, the earlier key/value pair is replaced.
+// Throws an error if the key is already present and the Batch rejects duplicate keys, or if the Batch would exceed its limits.
 func (batch *Batch) Add(key, value []byte) error {
-	if batch.Contains(key) {
+	return batch.add(newKeyValuePair(key, value))
+}
+
+// Delete adds a tombstone marker for the key in the Batch. If the key is already present in the Batch, the earlier key/value
+// pair is replaced by the tombstone marker.
+// Throws an error if the key is already present and the Batch rejects duplicate keys, or if the Batch would exceed its limits.
+func (batch *Batch) Delete(key []byte) error {
+	return batch.add(newDeletedKeyValuePair(key))
+}
+
+// add adds the KeyValuePair in the Batch (or replaces the existing KeyValuePair for the same key), if the Batch stays within its limits.
+func (batch *Batch) add(pair KeyValuePair) error {
+	position, exists := batch.index[string(pair.key)]
+	if exists && batch.rejectDuplicateKeys {
 		return errors.DuplicateKeyInBatchErr
 	}
-	batch.pairs = append(batch.pairs, newKeyValuePair(key, value))
+	sizeInBytes, entries := batch.sizeInBytes+sizeOf(pair), len(batch.pairs)+1
+	if exists {
+		sizeInBytes, entries = sizeInBytes-sizeOf(batch.pairs[position]), len(batch.pairs)
+	}
+	if batch.maxEntries > 0 && entries > batch.maxEntries {
+		return errors.TxnTooBigErr
+	}
+	if batch.maxSizeInBytes > 0 && sizeInBytes > batch.maxSizeInBytes {
+		return errors.TxnTooBigErr
+	}
+	if exists {
+		batch.pairs[position] = pair
+	} else {
+		batch.index[string(pair.key)] = len(batch.pairs)
+		batch.pairs = append(batch.pairs, pair)
+	}
+	batch.sizeInBytes = sizeInBytes
 	return nil
 }
 
+// clear removes all the key/value pairs from the Batch.
+func (batch *Batch) clear() {
+	batch.pairs = nil
+	batch.index = make(map[string]int)
+	batch.sizeInBytes = 0
+}
+
+// sizeOf returns the size of the key and the value of the KeyValuePair.
+func sizeOf(pair KeyValuePair) uint64 {
+	return uint64(len(pair.key) + len(pair.value))
+}
+
 // Get returns the value for the key, is the value is present in the batch.
 // Returns (Value, true) is the value is present in the Batch, else returns (nil, false).
+// A key that is deleted in the Batch does not have a value, so Get returns (nil, false) for it (refer to IsDeleted).
 func (batch *Batch) Get(key []byte) ([]byte, bool) {
-	for _, pair := range batch.pairs {
-		if bytes.Compare(pair.key, key) == 0 {
-			return pair.value, true
-		}
+	pair, ok := batch.pairFor(key)
+	if !ok || pair.isDeleted() {
+		return nil, false
 	}
-	return nil, false
+	return pair.value, true
+}
+
+// IsDeleted returns true if the key is deleted in the Batch, false otherwise.
+func (batch *Batch) IsDeleted(key []byte) bool {
+	pair, ok := batch.pairFor(key)
+	return ok && pair.isDeleted()
 }
 
-// Contains returns true is the key is present in the Batch, false otherwise.
+// Contains returns true is the key is present in the Batch (either with a value or as deleted), false otherwise.
 func (batch *Batch) Contains(key []byte) bool {
-	_, ok := batch.Get(key)
+	_, ok := batch.pairFor(key)
 	return ok
 }
 
+// keys returns all the keys of the Batch (either with a value or as deleted).
+func (batch *Batch) keys() [][]byte {
+	keys := make([][]byte, 0, len(batch.pairs))
+	for _, pair := range batch.pairs {
+		keys = append(keys, pair.key)
+	}
+	return keys
+}
+
+// fingerprints returns the FingerprintSet of all the keys of the Batch (either with a value or as deleted).
+func (batch *Batch) fingerprints() FingerprintSet {
+	fingerprints := make(FingerprintSet, len(batch.pairs))
+	for _, pair := range batch.pairs {
+		fingerprints.add(pair.key)
+	}
+	return fingerprints
+}
+
+// pairFor returns the KeyValuePair for the key, if the key is present in the Batch.
+func (batch *Batch) pairFor(key []byte) (KeyValuePair, bool) {
+	position, ok := batch.index[string(key)]
+	if !ok {
+		return KeyValuePair{}, false
+	}
+	return batch.pairs[position], true
+}
+
 // ToTimestampedBatch converts the batch to a TimestampedBatch.
 // TimestampedBatch also creates a doneChannel that will receive a notification when the transaction containing the TimestampedBatch is applied.
-// The notification is sent from TransactionExecutor.
+// The notification is sent from TransactionExecutor, and it carries the error if the TimestampedBatch could not be applied.
+// The doneChannel is buffered, so the TransactionExecutor does not wait for the clients to receive the notification.
 // ToTimestampedBatch also takes a callback which is a function that will be called when the transaction containing the
 // TimestampedBatch is committed. This will happen from TransactionExecutor.
 func (batch *Batch) ToTimestampedBatch(commitTimestamp uint64, commitCallback func()) TimestampedBatch {
 	return TimestampedBatch{
 		batch:          batch,
 		timestamp:      commitTimestamp,
-		doneChannel:    make(chan struct{}),
+		doneChannel:    make(chan error, 1),
 		commitCallback: commitCallback,
 	}
 }
 
+// SizeInBytes returns the total size of the keys and the values in the Batch.
+func (batch *Batch) SizeInBytes() uint64 {
+	return batch.sizeInBytes
+}
+
 // IsEmpty returns true is the Batch is empty, false otherwise
 func (batch *Batch) IsEmpty() bool {
 	return len(batch.pairs) == 0
@@ -1,6 +1,7 @@
 package txn
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"tinydb/pkg/kv/txn/errors"
@@ -18,7 +19,7 @@ func TestNonEmptyBatch(t *testing.T) {
 }
 
 func TestAddsDuplicateKeyInBatch(t *testing.T) {
-	batch := NewBatch()
+	batch := NewBatch().SetRejectDuplicateKeys(true)
 	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
 	err := batch.Add([]byte("HDD"), []byte("Hard disk"))
 
@@ -68,3 +69,85 @@ func TestGetsTheTimestampedBatch(t *testing.T) {
 	assert.Equal(t, uint64(1), timestampedBatch.timestamp)
 	assert.Equal(t, []KeyValuePair{newKeyValuePair([]byte("HDD"), []byte("Hard disk"))}, timestampedBatch.batch.pairs)
 }
+
+func TestDeletesAKeyInBatch(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Delete([]byte("HDD"))
+
+	_, ok := batch.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, true, batch.IsDeleted([]byte("HDD")))
+	assert.Equal(t, true, batch.Contains([]byte("HDD")))
+	assert.Equal(t, false, batch.IsEmpty())
+}
+
+func TestDeletesADuplicateKeyInBatch(t *testing.T) {
+	batch := NewBatch().SetRejectDuplicateKeys(true)
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+	err := batch.Delete([]byte("HDD"))
+
+	assert.Error(t, err)
+	assert.Equal(t, errors.DuplicateKeyInBatchErr, err)
+	assert.Equal(t, false, batch.IsDeleted([]byte("HDD")))
+}
+
+func TestAddsKeysInBatchUptoTheMaximumEntries(t *testing.T) {
+	batch := NewBoundedBatch(2, 0)
+	assert.Nil(t, batch.Add([]byte("HDD"), []byte("Hard disk")))
+	assert.Nil(t, batch.Delete([]byte("SSD")))
+
+	err := batch.Add([]byte("NVMe"), []byte("Non volatile memory"))
+	assert.Equal(t, errors.TxnTooBigErr, err)
+	assert.Equal(t, false, batch.Contains([]byte("NVMe")))
+}
+
+func TestAddsKeysInBatchUptoTheMaximumSize(t *testing.T) {
+	batch := NewBoundedBatch(0, 20)
+	assert.Nil(t, batch.Add([]byte("HDD"), []byte("Hard disk")))
+	assert.Equal(t, uint64(12), batch.SizeInBytes())
+
+	err := batch.Add([]byte("SSD"), []byte("Solid state"))
+	assert.Equal(t, errors.TxnTooBigErr, err)
+	assert.Equal(t, uint64(12), batch.SizeInBytes())
+
+	assert.Nil(t, batch.Add([]byte("SSD"), []byte("Solid")))
+	assert.Equal(t, uint64(20), batch.SizeInBytes())
+}
+
+func TestAddsManyKeysInBatch(t *testing.T) {
+	batch := NewBatch()
+	for count := 0; count < 100_000; count++ {
+		assert.Nil(t, batch.Add([]byte(fmt.Sprintf("key-%d", count)), []byte("value")))
+	}
+	value, ok := batch.Get([]byte("key-99999"))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestReplacesAKeyInBatch(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+	_ = batch.Add([]byte("SSD"), []byte("Solid state"))
+	assert.Nil(t, batch.Add([]byte("HDD"), []byte("Hard disk drive")))
+
+	value, ok := batch.Get([]byte("HDD"))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk drive"), value)
+	assert.Equal(t, 2, len(batch.pairs))
+	assert.Equal(t, uint64(32), batch.SizeInBytes())
+
+	assert.Nil(t, batch.Delete([]byte("HDD")))
+	assert.Equal(t, true, batch.IsDeleted([]byte("HDD")))
+	assert.Equal(t, uint64(17), batch.SizeInBytes())
+}
+
+func TestReplacesAKeyInBatchUptoTheMaximumSize(t *testing.T) {
+	batch := NewBoundedBatch(1, 12)
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+
+	assert.Nil(t, batch.Add([]byte("HDD"), []byte("Hard disk")))
+	assert.Equal(t, errors.TxnTooBigErr, batch.Add([]byte("HDD"), []byte("Hard disk drive")))
+
+	value, _ := batch.Get([]byte("HDD"))
+	assert.Equal(t, []byte("Hard disk"), value)
+}
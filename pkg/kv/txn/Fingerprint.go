@@ -0,0 +1,67 @@
+package txn
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+)
+
+// FingerprintSet is a hash set of 64-bit key fingerprints.
+// The Oracle tracks the read and the write sets of the transactions as FingerprintSets: a conflict check costs one lookup per key,
+// and the keys (and the values) of the transactions need not be held in memory.
+// Two different keys may have the same fingerprint, which results in a false conflict (the transaction is retried), never in a missed conflict.
+type FingerprintSet map[uint64]struct{}
+
+// fingerprintOf returns the 64-bit FNV-1a hash of the key.
+func fingerprintOf(key []byte) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write(key)
+	return hash.Sum64()
+}
+
+// add adds the fingerprint of the key to the FingerprintSet.
+func (set FingerprintSet) add(key []byte) {
+	set[fingerprintOf(key)] = struct{}{}
+}
+
+// contains returns true if the fingerprint is present in the FingerprintSet, false otherwise.
+func (set FingerprintSet) contains(fingerprint uint64) bool {
+	_, ok := set[fingerprint]
+	return ok
+}
+
+// containsAnyOf returns true if any fingerprint of the other FingerprintSet is present in the FingerprintSet.
+// It iterates over the smaller of the two sets.
+func (set FingerprintSet) containsAnyOf(other FingerprintSet) bool {
+	smaller, larger := other, set
+	if len(set) < len(other) {
+		smaller, larger = set, other
+	}
+	for fingerprint := range smaller {
+		if larger.contains(fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortedKeys is a list of keys in the increasing order. It is used to find if any key falls in a KeyRange (for the ranges
+// scanned by a transaction), which can not be answered by a FingerprintSet.
+type SortedKeys [][]byte
+
+// newSortedKeys creates SortedKeys from the keys.
+func newSortedKeys(keys [][]byte) SortedKeys {
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	return keys
+}
+
+// containsAnyKeyIn returns true if any key falls in the KeyRange, false otherwise.
+// It performs a binary search for the first key >= start of the KeyRange.
+func (keys SortedKeys) containsAnyKeyIn(keyRange KeyRange) bool {
+	index := sort.Search(len(keys), func(index int) bool {
+		return bytes.Compare(keys[index], keyRange.start) >= 0
+	})
+	return index < len(keys) && keyRange.contains(keys[index])
+}
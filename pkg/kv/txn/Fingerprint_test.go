@@ -0,0 +1,40 @@
+package txn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFingerprintSetContainsTheKey(t *testing.T) {
+	set := make(FingerprintSet)
+	set.add([]byte("HDD"))
+
+	assert.Equal(t, true, set.contains(fingerprintOf([]byte("HDD"))))
+	assert.Equal(t, false, set.contains(fingerprintOf([]byte("SSD"))))
+}
+
+func TestFingerprintSetContainsAnyOfTheOtherSet(t *testing.T) {
+	set := make(FingerprintSet)
+	set.add([]byte("HDD"))
+	set.add([]byte("SSD"))
+
+	other := make(FingerprintSet)
+	other.add([]byte("SSD"))
+	assert.Equal(t, true, set.containsAnyOf(other))
+	assert.Equal(t, true, other.containsAnyOf(set))
+
+	another := make(FingerprintSet)
+	another.add([]byte("NVMe"))
+	assert.Equal(t, false, set.containsAnyOf(another))
+	assert.Equal(t, false, set.containsAnyOf(make(FingerprintSet)))
+}
+
+func TestSortedKeysContainAKeyInTheRange(t *testing.T) {
+	keys := newSortedKeys([][]byte{[]byte("tape:lto"), []byte("disk:hdd")})
+
+	assert.Equal(t, true, keys.containsAnyKeyIn(newKeyRange([]byte("disk:"), []byte("disk;"))))
+	assert.Equal(t, true, keys.containsAnyKeyIn(newKeyRange([]byte("disk:ssd"), nil)))
+	assert.Equal(t, false, keys.containsAnyKeyIn(newKeyRange([]byte("disk:ssd"), []byte("tape:"))))
+	assert.Equal(t, false, keys.containsAnyKeyIn(newKeyRange([]byte("a"), []byte("disk:hdd"))))
+	assert.Equal(t, false, keys.containsAnyKeyIn(newKeyRange([]byte("z"), nil)))
+}
@@ -0,0 +1,124 @@
+package txn
+
+import (
+	"bytes"
+	"sort"
+	"tinydb/pkg/kv"
+	"tinydb/pkg/kv/mvcc"
+)
+
+// Iterator iterates over the keys in a range, in the increasing order, as seen by a transaction.
+// It merges the kv.Iterator over the kv.Workspace with the pending key/value pairs of the Batch (for a ReadWriteTransaction).
+// A key present in the Batch hides the same key in the kv.Workspace, and its value carries the beginTimestamp of the
+// transaction as the version (like the Get of ReadWriteTransaction). A key deleted in the Batch is not returned.
+// It is ESSENTIAL to Close the Iterator after use.
+type Iterator struct {
+	workspaceIterator *kv.Iterator
+	pendingPairs      []KeyValuePair
+	pendingVersion    uint64
+	key               []byte
+	value             mvcc.ValueWithVersion
+	valid             bool
+}
+
+// newIterator creates a new instance of Iterator and positions it at the first key of the range.
+func newIterator(workspaceIterator *kv.Iterator, pendingPairs []KeyValuePair, pendingVersion uint64) *Iterator {
+	iterator := &Iterator{
+		workspaceIterator: workspaceIterator,
+		pendingPairs:      pendingPairs,
+		pendingVersion:    pendingVersion,
+	}
+	iterator.moveToNext()
+	return iterator
+}
+
+// IsValid returns true if the Iterator is positioned at a key, false otherwise.
+func (iterator *Iterator) IsValid() bool {
+	return iterator.valid
+}
+
+// Key returns the key the Iterator is positioned at.
+func (iterator *Iterator) Key() []byte {
+	return iterator.key
+}
+
+// Value returns the value (with its version) of the key the Iterator is positioned at.
+func (iterator *Iterator) Value() mvcc.ValueWithVersion {
+	return iterator.value
+}
+
+// Next moves the Iterator to the next key. It is ESSENTIAL to call IsValid() before calling Next.
+func (iterator *Iterator) Next() {
+	iterator.moveToNext()
+}
+
+// Err returns the first error encountered while reading the kv.Workspace.
+func (iterator *Iterator) Err() error {
+	return iterator.workspaceIterator.Err()
+}
+
+// Close releases the resources held by the kv.Iterator.
+func (iterator *Iterator) Close() {
+	iterator.workspaceIterator.Close()
+	iterator.valid = false
+}
+
+// moveToNext positions the Iterator at the smaller of the next pending key and the next key of the kv.Workspace.
+// A key that is deleted in the Batch hides the same key in the kv.Workspace, and is not returned.
+func (iterator *Iterator) moveToNext() {
+	workspaceIterator := iterator.workspaceIterator
+	for len(iterator.pendingPairs) > 0 {
+		pair := iterator.pendingPairs[0]
+		comparison := -1
+		if workspaceIterator.IsValid() {
+			comparison = bytes.Compare(pair.key, workspaceIterator.Key())
+		}
+		if comparison > 0 {
+			break
+		}
+		if comparison == 0 {
+			workspaceIterator.Next()
+		}
+		iterator.pendingPairs = iterator.pendingPairs[1:]
+		if pair.isDeleted() {
+			continue
+		}
+		iterator.key = pair.key
+		iterator.value = mvcc.NewValueWithVersion(mvcc.NewValue(pair.value), iterator.pendingVersion)
+		iterator.valid = true
+		return
+	}
+	if !workspaceIterator.IsValid() {
+		iterator.valid = false
+		return
+	}
+	iterator.key, iterator.value, iterator.valid = workspaceIterator.Key(), workspaceIterator.Value(), true
+	workspaceIterator.Next()
+}
+
+// pendingPairsIn returns the key/value pairs of the Batch that fall in the KeyRange, in the increasing order of keys.
+func pendingPairsIn(batch *Batch, keyRange KeyRange) []KeyValuePair {
+	var pairs []KeyValuePair
+	for _, pair := range batch.pairs {
+		if keyRange.contains(pair.key) {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+	})
+	return pairs
+}
+
+// prefixEnd returns the smallest key that is greater than all the keys with the prefix, which is the (exclusive) end of
+// the range of the prefix. It returns nil if there is no such key (the prefix is empty or all of its bytes are 0xff).
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for index := len(end) - 1; index >= 0; index-- {
+		if end[index] < 0xff {
+			end[index] = end[index] + 1
+			return end[:index+1]
+		}
+	}
+	return nil
+}
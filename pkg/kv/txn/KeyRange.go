@@ -0,0 +1,22 @@
+package txn
+
+import "bytes"
+
+// KeyRange represents the range of keys [start, end) that is scanned by a ReadWriteTransaction.
+// A nil end means that the range is not bounded on the right.
+type KeyRange struct {
+	start []byte
+	end   []byte
+}
+
+func newKeyRange(start, end []byte) KeyRange {
+	return KeyRange{
+		start: start,
+		end:   end,
+	}
+}
+
+// contains returns true if the key falls in the KeyRange, false otherwise.
+func (keyRange KeyRange) contains(key []byte) bool {
+	return bytes.Compare(key, keyRange.start) >= 0 && (keyRange.end == nil || bytes.Compare(key, keyRange.end) < 0)
+}
@@ -3,13 +3,20 @@ package txn
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
+	"tinydb/pkg/kv/option"
 	txnErrors "tinydb/pkg/kv/txn/errors"
 )
 
 // CommittedTransaction is a concurrently running ReadWriteTransaction which is ready to be committed.
+// It only keeps the write set of the transaction: the fingerprints of the keys that are written (or deleted), and the
+// keys in the increasing order (to check against the ranges scanned by other transactions), only if such a check is
+// possible (refer to trackReadyToCommitTransaction). The values are not kept.
 type CommittedTransaction struct {
 	commitTimestamp uint64
-	transaction     *ReadWriteTransaction
+	writes          FingerprintSet
+	writeKeys       SortedKeys
 }
 
 // Oracle is the central authority that assigns begin and commit timestamp to the transactions.
@@ -21,6 +28,9 @@ type CommittedTransaction struct {
 // beginTimestampMark is used to indicate till what timestamp have the transactions begun. This information is used to clean up
 // the committedTransactions.
 // commitTimestampMark is used to block the new transactions, so all previous commits are visible to a new read.
+// With option.Options.TransactionLeakDeadline, the Oracle reports the transactions that are not discarded within the deadline.
+// serializableTransactions is the number of ReadWriteTransactions with option.SerializableSnapshotIsolation that have
+// not finished their beginTimestamp; only such transactions check their scanned ranges against the committed transactions.
 type Oracle struct {
 	lock                  sync.Mutex
 	executorLock          sync.Mutex
@@ -29,17 +39,22 @@ type Oracle struct {
 	beginTimestampMark    *TransactionTimestampMark
 	commitTimestampMark   *TransactionTimestampMark
 	committedTransactions []CommittedTransaction
+	leakedTransactions    atomic.Uint64
+
+	serializableTransactions atomic.Int64
 }
 
 // NewOracle creates a new instance of Oracle. It is called once in the entire application.
-// Oracle is initialized with nextTimestamp as 1.
-// If we were to implement durability using WAL, we would load the value of the nextTimestamp from WAL.
-// Every segment of WAL can contain the last commitTimestamp. In order to recover nextTimestamp, we can read the latest
-// WAL segment (only the footer where we place the last commitTimestamp), get the last commitTimestamp and add 1 to it.
-// As a part creating a new instance of NewOracle, we also mark beginTimestampMark and commitTimestampMark as finished for timestamp 0.
+// Oracle is initialized with nextTimestamp as 1 + the last commitTimestamp that is persisted in the kv.Workspace.
+// The kv.Workspace recovers the last commitTimestamp from the footers of the sealed WAL segments, the keys of the active
+// WAL segment and the footers of the SSTables. This ensures that the commits after a restart never reuse the versions
+// that are already on disk. For a new kv.Workspace, nextTimestamp is 1.
+// As a part creating a new instance of NewOracle, we also mark beginTimestampMark and commitTimestampMark as finished for timestamp nextTimestamp - 1.
+// The compactionWatermark is set as the compaction watermark of the kv.Workspace: the versions older than the
+// latest version <= watermark are not read by any transaction, so they can be dropped during compaction.
 func NewOracle(transactionExecutor *TransactionExecutor) *Oracle {
 	oracle := &Oracle{
-		nextTimestamp:       1,
+		nextTimestamp:       transactionExecutor.workspace.LastCommitTimestamp() + 1,
 		transactionExecutor: transactionExecutor,
 		beginTimestampMark:  NewTransactionTimestampMark(),
 		commitTimestampMark: NewTransactionTimestampMark(),
@@ -47,19 +62,44 @@ func NewOracle(transactionExecutor *TransactionExecutor) *Oracle {
 
 	oracle.beginTimestampMark.Finish(oracle.nextTimestamp - 1)
 	oracle.commitTimestampMark.Finish(oracle.nextTimestamp - 1)
+	transactionExecutor.workspace.SetCompactionWatermark(oracle.compactionWatermark)
 	return oracle
 }
 
+// compactionWatermark returns the DoneTill of beginTimestampMark, capped at the readableTimestamp of the
+// TransactionExecutor once it stops accepting writes, because the new transactions begin at the readableTimestamp
+// (refer to beginTimestampWithContext) and must find the versions they read.
+func (oracle *Oracle) compactionWatermark() uint64 {
+	watermark := oracle.beginTimestampMark.DoneTill()
+	if readableTimestamp, stopped := oracle.transactionExecutor.readableTimestamp(); stopped && readableTimestamp < watermark {
+		return readableTimestamp
+	}
+	return watermark
+}
+
+// BeginWithContext begins a new ReadWriteTransaction (refer to NewReadWriteTransactionWithContext).
+// It returns ctx.Err() if the ctx is done before the commits till the beginTimestamp of the transaction are applied.
+func (oracle *Oracle) BeginWithContext(ctx context.Context) (*ReadWriteTransaction, error) {
+	return NewReadWriteTransactionWithContext(ctx, oracle)
+}
+
+// BeginReadonlyWithContext begins a new ReadonlyTransaction (refer to NewReadonlyTransactionWithContext).
+// It returns ctx.Err() if the ctx is done before the commits till the beginTimestamp of the transaction are applied.
+func (oracle *Oracle) BeginReadonlyWithContext(ctx context.Context) (*ReadonlyTransaction, error) {
+	return NewReadonlyTransactionWithContext(ctx, oracle)
+}
+
 // CommittedTransactionLength returns the length of all the transactions that are committed and maintained in Oracle
 func (oracle *Oracle) CommittedTransactionLength() int {
 	return len(oracle.committedTransactions)
 }
 
-// Stop stops `beginTimestampMark`, `commitTimestampMark` and `transactionExecutor`.
+// Stop stops `transactionExecutor`, `beginTimestampMark` and `commitTimestampMark`.
+// The `transactionExecutor` is stopped first, because the batches that it applies while stopping finish their commitTimestamps.
 func (oracle *Oracle) Stop() {
+	oracle.transactionExecutor.Stop()
 	oracle.beginTimestampMark.Stop()
 	oracle.commitTimestampMark.Stop()
-	oracle.transactionExecutor.Stop()
 }
 
 // beginTimestamp returns the beginTimestamp of a transaction.
@@ -68,20 +108,72 @@ func (oracle *Oracle) Stop() {
 // This wait is to ensure that all the commits till beginTimestamp are applied.
 // This also means that all the Get operations return the values for keys where the commitTimestamp of the key <= beginTimestamp of the transaction.
 func (oracle *Oracle) beginTimestamp() uint64 {
-	oracle.lock.Lock()
-	beginTimestamp := oracle.nextTimestamp - 1
-	oracle.beginTimestampMark.Begin(beginTimestamp)
-	oracle.lock.Unlock()
-
-	_ = oracle.commitTimestampMark.WaitForMark(context.Background(), beginTimestamp)
+	beginTimestamp, _ := oracle.beginTimestampWithContext(context.Background())
 	return beginTimestamp
 }
 
+// beginTimestampWithContext returns the beginTimestamp of a transaction, like beginTimestamp, but it gives up waiting on
+// the commitTimestampMark when the ctx is done.
+// If the ctx is done, the beginTimestamp is finished (so the beginTimestampMark does not get stuck), and ctx.Err() is returned.
+// Once the TransactionExecutor stops accepting writes, the beginTimestamp is capped at its readableTimestamp: the commit
+// timestamps of the groups that failed are never finished, and their key/value pairs must not be read. A transaction
+// that is waiting for such a commit timestamp begins again at the readableTimestamp.
+func (oracle *Oracle) beginTimestampWithContext(ctx context.Context) (uint64, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		oracle.lock.Lock()
+		beginTimestamp, capped := oracle.readTimestamp()
+		oracle.beginTimestampMark.Begin(beginTimestamp)
+		oracle.lock.Unlock()
+
+		err := oracle.waitForCommitsTill(ctx, beginTimestamp, capped)
+		if err == nil {
+			return beginTimestamp, nil
+		}
+		oracle.beginTimestampMark.Finish(beginTimestamp)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, ctxErr
+		}
+	}
+}
+
+// readTimestamp returns nextTimestamp - 1, capped at the readableTimestamp of the TransactionExecutor (and true) once
+// it stops accepting writes. It is called with the lock held.
+func (oracle *Oracle) readTimestamp() (uint64, bool) {
+	timestamp := oracle.nextTimestamp - 1
+	if readableTimestamp, stopped := oracle.transactionExecutor.readableTimestamp(); stopped && readableTimestamp < timestamp {
+		return readableTimestamp, true
+	}
+	return timestamp, false
+}
+
+// waitForCommitsTill waits till all the commits till the timestamp are applied. A timestamp that is not capped (refer
+// to readTimestamp) may belong to a group that fails, so the wait also gives up when the TransactionExecutor stops
+// accepting writes, and returns an error.
+func (oracle *Oracle) waitForCommitsTill(ctx context.Context, timestamp uint64, capped bool) error {
+	if capped || oracle.commitTimestampMark.DoneTill() >= timestamp {
+		return oracle.commitTimestampMark.WaitForMark(ctx, timestamp)
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-oracle.transactionExecutor.writesStopped():
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+	return oracle.commitTimestampMark.WaitForMark(waitCtx, timestamp)
+}
+
 // mayBeCommitTimestampFor returns the commitTimestamp for a  transaction if there are no conflicts.
 // A ReadWriteTransaction Tx conflicts with other transaction if:
-// the keys read by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
+// the keys read (or written) by the transaction Tx are modified (written or deleted) by another transaction that has the
+// commitTimestamp > beginTimestampOf(Tx). More details are available in hasConflictFor.
 // If there are no conflicts:
-// 1. the current transaction is marked as `beginFinished` by invoking finishBeginTimestampForReadWriteTransaction.
+// 1. the beginTimestamp of the current transaction is finished (refer to ReadWriteTransaction.finishBeginTimestamp).
 // 2. committedTransactions are cleaned up.
 // 3. commitTimestamp is assigned to the transaction and the nextTimestamp is increased by 1
 // 4. The current transaction is tracked as CommittedTransaction
@@ -91,33 +183,66 @@ func (oracle *Oracle) mayBeCommitTimestampFor(transaction *ReadWriteTransaction)
 	oracle.lock.Lock()
 	defer oracle.lock.Unlock()
 
-	if oracle.hasConflictFor(transaction) {
+	writes := transaction.batch.fingerprints()
+	if oracle.hasConflictFor(transaction, writes) {
 		return 0, txnErrors.ConflictErr
 	}
 
-	oracle.finishBeginTimestampForReadWriteTransaction(transaction)
+	transaction.finishBeginTimestamp()
 	oracle.cleanupCommittedTransactions()
 
 	commitTimestamp := oracle.nextTimestamp
 	oracle.nextTimestamp = oracle.nextTimestamp + 1
 
-	oracle.trackReadyToCommitTransaction(transaction, commitTimestamp)
+	transaction.commitTimestamp = commitTimestamp
+	oracle.trackReadyToCommitTransaction(transaction, commitTimestamp, writes)
 	oracle.commitTimestampMark.Begin(commitTimestamp)
 	return commitTimestamp, nil
 }
 
+// abandonCommitTimestamp gives up the commitTimestamp of a transaction that is never submitted to the TransactionExecutor
+// (refer to ReadWriteTransaction.CommitWithContext). It stops tracking the transaction as a CommittedTransaction, because
+// the writes of the transaction are never applied, and finishes the commitTimestamp in the commitTimestampMark, so that
+// the new transactions do not wait for it.
+func (oracle *Oracle) abandonCommitTimestamp(commitTimestamp uint64) {
+	oracle.lock.Lock()
+	defer oracle.lock.Unlock()
+
+	remainingTransactions := oracle.committedTransactions[:0]
+	for _, transaction := range oracle.committedTransactions {
+		if transaction.commitTimestamp == commitTimestamp {
+			continue
+		}
+		remainingTransactions = append(remainingTransactions, transaction)
+	}
+	oracle.committedTransactions = remainingTransactions
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+}
+
 // hasConflictFor determines of the transaction has a conflict with other concurrent transactions.
-// A ReadWriteTransaction Tx conflicts with other transaction if:
-// the keys read by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
-// ReadWriteTransaction tracks its read keys in the `reads` property.
-func (oracle *Oracle) hasConflictFor(transaction *ReadWriteTransaction) bool {
+// A ReadWriteTransaction Tx conflicts with another transaction that has the commitTimestamp > beginTimestampOf(Tx) if:
+// 1. the keys written (or deleted) by the transaction Tx are also written by the other transaction (WW conflict), or
+// 2. with option.SerializableSnapshotIsolation, the keys read by the transaction Tx, or any key inside the ranges scanned by
+// the transaction Tx, are modified (written or deleted) by the other transaction (RW conflict).
+// ReadWriteTransaction tracks the fingerprints of its read keys in the `reads` property and its scanned ranges in the `readRanges` property.
+// The key checks cost O(min(reads, writes)) lookups per committed transaction, and every range check is a binary search.
+// The transaction itself is not among the committedTransactions, it is tracked only after the conflict check.
+func (oracle *Oracle) hasConflictFor(transaction *ReadWriteTransaction, writes FingerprintSet) bool {
 	for _, committedTransaction := range oracle.committedTransactions {
 		if committedTransaction.commitTimestamp <= transaction.beginTimestamp {
 			continue
 		}
-
-		for _, key := range transaction.reads {
-			if committedTransaction.transaction.batch.Contains(key) {
+		if committedTransaction.writes.containsAnyOf(writes) {
+			return true
+		}
+		if transaction.isolationLevel != option.SerializableSnapshotIsolation {
+			continue
+		}
+		if committedTransaction.writes.containsAnyOf(transaction.reads) {
+			return true
+		}
+		for _, keyRange := range transaction.readRanges {
+			if committedTransaction.writeKeys.containsAnyKeyIn(keyRange) {
 				return true
 			}
 		}
@@ -129,9 +254,56 @@ func (oracle *Oracle) hasConflictFor(transaction *ReadWriteTransaction) bool {
 // This is an indication to the TransactionTimestampMark that all the transactions upto a given `beginTimestamp`
 // are done. This information will be used in cleaning up the committed transactions.
 func (oracle *Oracle) finishBeginTimestampForReadWriteTransaction(transaction *ReadWriteTransaction) {
+	if transaction.isolationLevel == option.SerializableSnapshotIsolation {
+		oracle.serializableTransactions.Add(-1)
+	}
 	oracle.beginTimestampMark.Finish(transaction.beginTimestamp)
 }
 
+// beginTimestampForReadWriteTransaction returns the beginTimestamp of a ReadWriteTransaction with the isolationLevel
+// (refer to beginTimestampWithContext). A transaction with option.SerializableSnapshotIsolation is counted in the
+// serializableTransactions before it gets the beginTimestamp, so that every transaction that commits after (or at) the
+// beginTimestamp keeps its write keys for the range checks of this transaction (refer to trackReadyToCommitTransaction).
+func (oracle *Oracle) beginTimestampForReadWriteTransaction(ctx context.Context, isolationLevel option.IsolationLevel) (uint64, error) {
+	serializable := isolationLevel == option.SerializableSnapshotIsolation
+	if serializable {
+		oracle.serializableTransactions.Add(1)
+	}
+	beginTimestamp, err := oracle.beginTimestampWithContext(ctx)
+	if err != nil && serializable {
+		oracle.serializableTransactions.Add(-1)
+	}
+	return beginTimestamp, err
+}
+
+// LeakedTransactions returns the number of transactions that were not discarded within the TransactionLeakDeadline.
+func (oracle *Oracle) LeakedTransactions() uint64 {
+	return oracle.leakedTransactions.Load()
+}
+
+// watchForLeak returns a timer that reports the transaction with the beginTimestamp as leaked, if the transaction is
+// not discarded within the TransactionLeakDeadline. It returns nil if the TransactionLeakDeadline is not set.
+// A leaked transaction holds back the beginTimestampMark, so the committed transactions are not cleaned up and the
+// compaction watermark does not advance.
+func (oracle *Oracle) watchForLeak(beginTimestamp uint64) *time.Timer {
+	deadline := oracle.transactionExecutor.workspace.Options().TransactionLeakDeadline
+	if deadline <= 0 {
+		return nil
+	}
+	return time.AfterFunc(deadline, func() {
+		oracle.leakedTransactions.Add(1)
+		//TODO: Removes println in favor of logging
+		println("transaction with beginTimestamp ", beginTimestamp, " is not discarded after ", deadline.String())
+	})
+}
+
+// stopWatchingForLeak stops the timer returned by watchForLeak.
+func stopWatchingForLeak(leakTimer *time.Timer) {
+	if leakTimer != nil {
+		leakTimer.Stop()
+	}
+}
+
 // finishBeginTimestampForReadonlyTransaction indicates that the beginTimestamp of the transaction is finished.
 func (oracle *Oracle) finishBeginTimestampForReadonlyTransaction(transaction *ReadonlyTransaction) {
 	oracle.beginTimestampMark.Finish(transaction.beginTimestamp)
@@ -155,10 +327,17 @@ func (oracle *Oracle) cleanupCommittedTransactions() {
 	oracle.committedTransactions = updatedCommittedTransactions
 }
 
-// trackReadyToCommitTransaction tracks all the transactions that are ready to be committed.
-func (oracle *Oracle) trackReadyToCommitTransaction(transaction *ReadWriteTransaction, commitTimestamp uint64) {
-	oracle.committedTransactions = append(oracle.committedTransactions, CommittedTransaction{
+// trackReadyToCommitTransaction tracks all the transactions that are ready to be committed, along with their write sets.
+// The write keys are needed only for the range checks of the transactions with option.SerializableSnapshotIsolation that
+// began before the commitTimestamp, so they are kept only if such a transaction is running. A transaction that begins
+// later gets a beginTimestamp >= commitTimestamp, and never checks for conflicts against this transaction.
+func (oracle *Oracle) trackReadyToCommitTransaction(transaction *ReadWriteTransaction, commitTimestamp uint64, writes FingerprintSet) {
+	committedTransaction := CommittedTransaction{
 		commitTimestamp: commitTimestamp,
-		transaction:     transaction,
-	})
+		writes:          writes,
+	}
+	if oracle.serializableTransactions.Load() > 0 {
+		committedTransaction.writeKeys = newSortedKeys(transaction.batch.keys())
+	}
+	oracle.committedTransactions = append(oracle.committedTransactions, committedTransaction)
 }
@@ -0,0 +1,60 @@
+package txn
+
+import (
+	"fmt"
+	"testing"
+	"tinydb/pkg/kv"
+	"tinydb/pkg/kv/option"
+)
+
+// BenchmarkConflictCheckUnderContention measures hasConflictFor for a transaction that reads and writes `keysPerTransaction`
+// keys, against `committedTransactions` concurrent transactions that write `keysPerTransaction` keys each.
+// The committed transactions pick their keys from the same (small) key space. With contention, the transaction reads from
+// that key space as well and conflicts; without contention, it reads other keys and every committed transaction is checked.
+func BenchmarkConflictCheckUnderContention(b *testing.B) {
+	const keySpace = 1024
+	for _, contention := range []bool{true, false} {
+		for _, committedTransactions := range []int{16, 256} {
+			for _, keysPerTransaction := range []int{8, 128} {
+				name := fmt.Sprintf("contention=%v/committed=%d/keys=%d", contention, committedTransactions, keysPerTransaction)
+				b.Run(name, func(b *testing.B) {
+					benchmarkConflictCheck(b, contention, committedTransactions, keysPerTransaction, keySpace)
+				})
+			}
+		}
+	}
+}
+
+func benchmarkConflictCheck(b *testing.B, contention bool, committedTransactions int, keysPerTransaction int, keySpace int) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(b.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	for index := 0; index < committedTransactions; index++ {
+		committed := NewReadWriteTransaction(oracle)
+		for keyIndex := 0; keyIndex < keysPerTransaction; keyIndex++ {
+			_ = committed.PutOrUpdate(benchmarkKey((index*keysPerTransaction+keyIndex)%keySpace), []byte("value"))
+		}
+		oracle.trackReadyToCommitTransaction(committed, uint64(index+1), committed.batch.fingerprints())
+	}
+
+	transaction := NewReadWriteTransaction(oracle)
+	for keyIndex := 0; keyIndex < keysPerTransaction; keyIndex++ {
+		readKeyIndex := (keyIndex * 7) % keySpace
+		if !contention {
+			readKeyIndex = 2*keySpace + keyIndex
+		}
+		transaction.reads.add(benchmarkKey(readKeyIndex))
+		_ = transaction.PutOrUpdate(benchmarkKey(keySpace+keyIndex), []byte("value"))
+	}
+	writes := transaction.batch.fingerprints()
+
+	b.ResetTimer()
+	for iteration := 0; iteration < b.N; iteration++ {
+		oracle.hasConflictFor(transaction, writes)
+	}
+}
+
+func benchmarkKey(index int) []byte {
+	return []byte(fmt.Sprintf("key-%06d", index))
+}
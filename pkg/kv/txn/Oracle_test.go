@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"tinydb/pkg/kv"
+	"tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
 	"tinydb/pkg/kv/txn/errors"
 )
@@ -123,3 +124,156 @@ func TestErrorsForOneTransaction(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, errors.ConflictErr, err)
 }
+
+func TestGetsTheBeginTimestampAfterARestart(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+	workspace, _ := kv.NewWorkspace(options)
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 4), mvcc.NewValue([]byte("Hard disk")))
+	workspace.Stop()
+
+	recovered, _ := kv.Open(options)
+	defer recovered.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(recovered))
+	assert.Equal(t, uint64(4), oracle.beginTimestamp())
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("SSD"), []byte("Solid state"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(transaction)
+	assert.Equal(t, uint64(5), commitTimestamp)
+}
+
+func TestErrorsForATransactionThatReadsAKeyDeletedByAnotherTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	aTransaction := NewReadWriteTransaction(oracle)
+	_ = aTransaction.Delete([]byte("HDD"))
+
+	anotherTransaction := NewReadWriteTransaction(oracle)
+	anotherTransaction.Get([]byte("HDD"))
+	_ = anotherTransaction.PutOrUpdate([]byte("SSD"), []byte("Solid state"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	_, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	assert.Error(t, err)
+	assert.Equal(t, errors.ConflictErr, err)
+}
+
+func TestErrorsForATransactionThatScansARangeInWhichAnotherTransactionInsertsAKey(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	aTransaction := NewReadWriteTransaction(oracle)
+	_ = aTransaction.PutOrUpdate([]byte("disk:nvme"), []byte("Non volatile memory"))
+
+	anotherTransaction := NewReadWriteTransaction(oracle)
+	anotherTransaction.ScanPrefix([]byte("disk:")).Close()
+	_ = anotherTransaction.PutOrUpdate([]byte("total"), []byte("0"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	_, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	assert.Error(t, err)
+	assert.Equal(t, errors.ConflictErr, err)
+}
+
+func TestGetsCommitTimestampForATransactionThatScansARangeOutsideTheKeysOfAnotherTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	aTransaction := NewReadWriteTransaction(oracle)
+	_ = aTransaction.PutOrUpdate([]byte("tape:lto"), []byte("Tape"))
+
+	anotherTransaction := NewReadWriteTransaction(oracle)
+	anotherTransaction.Scan([]byte("disk:"), []byte("tape:")).Close()
+	_ = anotherTransaction.PutOrUpdate([]byte("total"), []byte("0"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	commitTimestamp, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), commitTimestamp)
+}
+
+func TestKeepsTheWriteKeysOfACommittedTransactionOnlyForTheRunningSerializableTransactions(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	aTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, option.SnapshotIsolation)
+	_ = aTransaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	assert.Nil(t, oracle.committedTransactions[0].writeKeys)
+
+	serializableTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, option.SerializableSnapshotIsolation)
+	defer serializableTransaction.Discard()
+
+	anotherTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, option.SnapshotIsolation)
+	_ = anotherTransaction.PutOrUpdate([]byte("SSD"), []byte("Solid state drive"))
+	commitTimestamp, _ = oracle.mayBeCommitTimestampFor(anotherTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	lastCommittedTransaction := oracle.committedTransactions[oracle.CommittedTransactionLength()-1]
+	assert.Equal(t, SortedKeys{[]byte("SSD")}, lastCommittedTransaction.writeKeys)
+}
+
+func TestErrorsForTransactionsThatWriteTheSameKeyInSnapshotIsolation(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	aTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, option.SnapshotIsolation)
+	_ = aTransaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+
+	anotherTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, option.SnapshotIsolation)
+	_ = anotherTransaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk drive"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	_, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	assert.Error(t, err)
+	assert.Equal(t, errors.ConflictErr, err)
+}
+
+func TestAllowsWriteSkewInSnapshotIsolationButNotInSerializableSnapshotIsolation(t *testing.T) {
+	for _, isolationLevel := range []option.IsolationLevel{option.SnapshotIsolation, option.SerializableSnapshotIsolation} {
+		workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+		oracle := NewOracle(NewTransactionExecutor(workspace))
+
+		aTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, isolationLevel)
+		aTransaction.Get([]byte("SSD"))
+		_ = aTransaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+
+		anotherTransaction := NewReadWriteTransactionWithIsolationLevel(oracle, isolationLevel)
+		anotherTransaction.Get([]byte("HDD"))
+		_ = anotherTransaction.PutOrUpdate([]byte("SSD"), []byte("Solid state"))
+
+		commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+		oracle.commitTimestampMark.Finish(commitTimestamp)
+
+		_, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+		if isolationLevel == option.SnapshotIsolation {
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, errors.ConflictErr, err)
+		}
+		workspace.Stop()
+	}
+}
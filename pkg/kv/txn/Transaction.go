@@ -1,81 +1,218 @@
 package txn
 
 import (
+	"context"
+	"sync/atomic"
+	"time"
 	"tinydb/pkg/kv"
 	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
 	"tinydb/pkg/kv/txn/errors"
 )
 
+// MaxKeySizeInBytes is the maximum size of a key that can be written in a ReadWriteTransaction.
+const MaxKeySizeInBytes = 1 << 20
+
+// MaxValueSizeInBytes is the maximum size of a value that can be written in a ReadWriteTransaction.
+// A value is also bounded by option.Options.MaxBatchSizeInBytes, because it is a part of the Batch of its transaction.
+const MaxValueSizeInBytes = 64 << 20
+
 // ReadonlyTransaction represents a read-only transaction.
 // A ReadonlyTransaction is assigned a beginTimestamp everytime it starts and can only perform a `get` operation.
+// A ReadonlyTransaction must be discarded (refer to Discard) once it is done.
 type ReadonlyTransaction struct {
 	beginTimestamp uint64
 	workspace      *kv.Workspace
 	oracle         *Oracle
+	discarded      atomic.Bool
+	leakTimer      *time.Timer
 }
 
 // ReadWriteTransaction represents a read-write transaction.
 // A ReadWriteTransaction is assigned a beginTimestamp everytime it starts, and a commitTimestamp every time
 // it is ready to commit and there are not RW conflicts. (More on this in Oracle).
-// A ReadWriteTransaction also tracks the keys that are read in `reads: [][]byte`.
-// This tracking is essential to determine RW conflict.
+// A ReadWriteTransaction also tracks the fingerprints of the keys that are read in `reads: FingerprintSet` and the ranges
+// that are scanned in `readRanges: []KeyRange`.
+// This tracking is essential to determine RW conflict (including phantoms: keys inserted in a scanned range).
+// The isolationLevel decides which conflicts are checked when the transaction commits (refer to option.IsolationLevel).
+// readErr is the first error returned by Get, the transaction can not be committed after such an error.
+// A ReadWriteTransaction is discarded when it commits (successfully or not); it must be discarded (refer to Discard) if
+// it does not commit.
 type ReadWriteTransaction struct {
-	beginTimestamp uint64
-	batch          *Batch
-	reads          [][]byte
-	workspace      *kv.Workspace
-	oracle         *Oracle
+	beginTimestamp  uint64
+	commitTimestamp uint64
+	batch           *Batch
+	reads           FingerprintSet
+	readRanges      []KeyRange
+	isolationLevel  option.IsolationLevel
+	workspace       *kv.Workspace
+	oracle          *Oracle
+	discarded       atomic.Bool
+	leakTimer       *time.Timer
+	readErr         error
 }
 
 // NewReadonlyTransaction creates a new instance of ReadonlyTransaction.
 func NewReadonlyTransaction(oracle *Oracle) *ReadonlyTransaction {
+	return newReadonlyTransaction(oracle, oracle.beginTimestamp())
+}
+
+// NewReadonlyTransactionWithContext creates a new instance of ReadonlyTransaction, like NewReadonlyTransaction, but it
+// returns ctx.Err() if the ctx is done before the commits till the beginTimestamp are applied.
+func NewReadonlyTransactionWithContext(ctx context.Context, oracle *Oracle) (*ReadonlyTransaction, error) {
+	beginTimestamp, err := oracle.beginTimestampWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newReadonlyTransaction(oracle, beginTimestamp), nil
+}
+
+// newReadonlyTransaction creates a new instance of ReadonlyTransaction with the beginTimestamp.
+func newReadonlyTransaction(oracle *Oracle, beginTimestamp uint64) *ReadonlyTransaction {
 	return &ReadonlyTransaction{
-		beginTimestamp: oracle.beginTimestamp(),
+		beginTimestamp: beginTimestamp,
 		oracle:         oracle,
 		workspace:      oracle.transactionExecutor.workspace,
+		leakTimer:      oracle.watchForLeak(beginTimestamp),
 	}
 }
 
-// NewReadWriteTransaction creates a new instance of ReadWriteTransaction.
+// NewReadWriteTransaction creates a new instance of ReadWriteTransaction with the IsolationLevel of the kv.Workspace options.
 func NewReadWriteTransaction(oracle *Oracle) *ReadWriteTransaction {
+	return NewReadWriteTransactionWithIsolationLevel(oracle, oracle.transactionExecutor.workspace.Options().IsolationLevel)
+}
+
+// NewReadWriteTransactionWithIsolationLevel creates a new instance of ReadWriteTransaction with the isolationLevel.
+// The Batch of the transaction is bounded by the MaxBatchEntries and the MaxBatchSizeInBytes of the kv.Workspace options,
+// and rejects duplicate keys if RejectDuplicateKeys is set.
+func NewReadWriteTransactionWithIsolationLevel(oracle *Oracle, isolationLevel option.IsolationLevel) *ReadWriteTransaction {
+	beginTimestamp, _ := oracle.beginTimestampForReadWriteTransaction(context.Background(), isolationLevel)
+	return newReadWriteTransaction(oracle, isolationLevel, beginTimestamp)
+}
+
+// NewReadWriteTransactionWithContext creates a new instance of ReadWriteTransaction, like NewReadWriteTransaction, but it
+// returns ctx.Err() if the ctx is done before the commits till the beginTimestamp are applied.
+func NewReadWriteTransactionWithContext(ctx context.Context, oracle *Oracle) (*ReadWriteTransaction, error) {
+	isolationLevel := oracle.transactionExecutor.workspace.Options().IsolationLevel
+	beginTimestamp, err := oracle.beginTimestampForReadWriteTransaction(ctx, isolationLevel)
+	if err != nil {
+		return nil, err
+	}
+	return newReadWriteTransaction(oracle, isolationLevel, beginTimestamp), nil
+}
+
+// newReadWriteTransaction creates a new instance of ReadWriteTransaction with the isolationLevel and the beginTimestamp.
+func newReadWriteTransaction(oracle *Oracle, isolationLevel option.IsolationLevel, beginTimestamp uint64) *ReadWriteTransaction {
+	options := oracle.transactionExecutor.workspace.Options()
 	return &ReadWriteTransaction{
-		beginTimestamp: oracle.beginTimestamp(),
-		batch:          NewBatch(),
+		beginTimestamp: beginTimestamp,
+		batch:          NewBoundedBatch(options.MaxBatchEntries, options.MaxBatchSizeInBytes).SetRejectDuplicateKeys(options.RejectDuplicateKeys),
+		reads:          make(FingerprintSet),
+		isolationLevel: isolationLevel,
 		oracle:         oracle,
 		workspace:      oracle.transactionExecutor.workspace,
+		leakTimer:      oracle.watchForLeak(beginTimestamp),
 	}
 }
 
 // Get performs a get operation from the kv.Workspace.
-// It returns a pair  of (mvcc.ValueWithVersion and true) if the value exists for the key, (nil, false) otherwise.
-func (transaction *ReadonlyTransaction) Get(key []byte) (mvcc.ValueWithVersion, bool) {
+// It returns (mvcc.ValueWithVersion, true, nil) if the value exists for the key, (nil, false, nil) otherwise.
+// It returns the error if the value can not be read from the value log (refer to kv.Workspace.Get).
+func (transaction *ReadonlyTransaction) Get(key []byte) (mvcc.ValueWithVersion, bool, error) {
 	versionedKey := mvcc.NewVersionedKey(key, transaction.beginTimestamp)
 	return transaction.workspace.Get(versionedKey)
 }
 
-// FinishBeginTimestampForReadonlyTransaction indicates the end of ReadonlyTransaction.
+// Scan returns an Iterator over the keys in the range [start, end), in the increasing order.
+// It returns the latest version of each key that is visible at the beginTimestamp, and skips the deleted keys.
+// A nil end means that the range is not bounded on the right. It is ESSENTIAL to Close the Iterator after use.
+func (transaction *ReadonlyTransaction) Scan(start []byte, end []byte) *Iterator {
+	return newIterator(transaction.workspace.Scan(start, end, transaction.beginTimestamp), nil, transaction.beginTimestamp)
+}
+
+// ScanPrefix returns an Iterator over all the keys that begin with the prefix, in the increasing order.
+func (transaction *ReadonlyTransaction) ScanPrefix(prefix []byte) *Iterator {
+	return transaction.Scan(prefix, prefixEnd(prefix))
+}
+
+// Discard indicates the end of ReadonlyTransaction.
 // It is used to indicate the TransactionTimestampMark inside Oracle that all the transactions upto a given `beginTimestamp`
 // are done. (More on this in Oracle).
-func (transaction *ReadonlyTransaction) FinishBeginTimestampForReadonlyTransaction() {
+// Discard can be called more than once, only the first call finishes the beginTimestamp.
+func (transaction *ReadonlyTransaction) Discard() {
+	if !transaction.discarded.CompareAndSwap(false, true) {
+		return
+	}
+	stopWatchingForLeak(transaction.leakTimer)
 	transaction.oracle.finishBeginTimestampForReadonlyTransaction(transaction)
 }
 
+// FinishBeginTimestampForReadonlyTransaction indicates the end of ReadonlyTransaction. It is the same as Discard.
+func (transaction *ReadonlyTransaction) FinishBeginTimestampForReadonlyTransaction() {
+	transaction.Discard()
+}
+
 // Get performs a get operation from the kv.Workspace.
-// It returns a pair  of (mvcc.ValueWithVersion and true) if the value exists for the key, (nil, false) otherwise.
+// It returns (mvcc.ValueWithVersion, true, nil) if the value exists for the key, (nil, false, nil) otherwise.
 // Unlike the Get of ReadonlyTransaction, reads are tracked inside the Get of ReadWriteTransaction.
-func (transaction *ReadWriteTransaction) Get(key []byte) (mvcc.ValueWithVersion, bool) {
+// A key that is deleted in the same transaction does not exist for the transaction, and it is not tracked as a read.
+// If the value can not be read from the value log (refer to kv.Workspace.Get), Get returns the error and the transaction
+// fails: its Commit returns the same error, so that no write is committed based on a value that could not be read.
+func (transaction *ReadWriteTransaction) Get(key []byte) (mvcc.ValueWithVersion, bool, error) {
 	if value, ok := transaction.batch.Get(key); ok {
-		return mvcc.NewValueWithVersion(mvcc.NewValue(value), transaction.beginTimestamp), true
+		return mvcc.NewValueWithVersion(mvcc.NewValue(value), transaction.beginTimestamp), true, nil
 	}
-	transaction.reads = append(transaction.reads, key)
+	if transaction.batch.IsDeleted(key) {
+		return mvcc.EmptyValueWithZeroVersion(), false, nil
+	}
+	transaction.reads.add(key)
 
 	versionedKey := mvcc.NewVersionedKey(key, transaction.beginTimestamp)
-	return transaction.workspace.Get(versionedKey)
+	value, ok, err := transaction.workspace.Get(versionedKey)
+	if err != nil && transaction.readErr == nil {
+		transaction.readErr = err
+	}
+	return value, ok, err
+}
+
+// Scan returns an Iterator over the keys in the range [start, end), in the increasing order.
+// It returns the latest version of each key that is visible at the beginTimestamp, and skips the deleted keys.
+// The key/value pairs of the Batch that fall in the range are also returned, and they take precedence over the kv.Workspace.
+// The entire range is tracked as a read (even if the Iterator is not moved till the end), so that a concurrent transaction
+// that writes any key in the range causes a conflict.
+// A nil end means that the range is not bounded on the right. It is ESSENTIAL to Close the Iterator after use.
+func (transaction *ReadWriteTransaction) Scan(start []byte, end []byte) *Iterator {
+	keyRange := newKeyRange(start, end)
+	transaction.readRanges = append(transaction.readRanges, keyRange)
+	return newIterator(
+		transaction.workspace.Scan(start, end, transaction.beginTimestamp),
+		pendingPairsIn(transaction.batch, keyRange),
+		transaction.beginTimestamp,
+	)
+}
+
+// ScanPrefix returns an Iterator over all the keys that begin with the prefix, in the increasing order.
+func (transaction *ReadWriteTransaction) ScanPrefix(prefix []byte) *Iterator {
+	return transaction.Scan(prefix, prefixEnd(prefix))
 }
 
-// PutOrUpdate adds the key/value pair to the Batch inside ReadWriteTransaction.
-// It returns an error if an attempt is made to add the duplicate key to the ReadWriteTransaction.
+// PutOrUpdate adds the key/value pair to the Batch inside ReadWriteTransaction. A key that is already written (or deleted)
+// in the transaction is overwritten, so the last write wins and the reads inside the transaction return the latest value.
+// With option.Options.RejectDuplicateKeys, it returns an error if an attempt is made to add the duplicate key to the ReadWriteTransaction.
+// It returns errors.TxnTooBigErr if the Batch would exceed the MaxBatchEntries or the MaxBatchSizeInBytes.
+// It returns errors.KeyTooLargeErr (or errors.ValueTooLargeErr) if the key exceeds MaxKeySizeInBytes (or the value
+// exceeds MaxValueSizeInBytes), without adding the key/value pair to the Batch.
+// It returns errors.TxnDiscardedErr if the transaction is discarded (or committed).
 func (transaction *ReadWriteTransaction) PutOrUpdate(key []byte, value []byte) error {
+	if transaction.discarded.Load() {
+		return errors.TxnDiscardedErr
+	}
+	if len(key) > MaxKeySizeInBytes {
+		return errors.KeyTooLargeErr
+	}
+	if len(value) > MaxValueSizeInBytes {
+		return errors.ValueTooLargeErr
+	}
 	err := transaction.batch.Add(key, value)
 	if err != nil {
 		return err
@@ -83,6 +220,21 @@ func (transaction *ReadWriteTransaction) PutOrUpdate(key []byte, value []byte) e
 	return nil
 }
 
+// Delete adds a tombstone marker for the key to the Batch inside ReadWriteTransaction.
+// The key is deleted in the kv.Workspace at the commitTimestamp, and the delete is considered a write during conflict detection.
+// Like PutOrUpdate, the delete overwrites an earlier write of the key in the transaction (unless duplicate keys are rejected),
+// and it returns errors.TxnTooBigErr if the Batch would exceed its limits (or errors.KeyTooLargeErr if the key exceeds MaxKeySizeInBytes).
+// It returns errors.TxnDiscardedErr if the transaction is discarded (or committed).
+func (transaction *ReadWriteTransaction) Delete(key []byte) error {
+	if transaction.discarded.Load() {
+		return errors.TxnDiscardedErr
+	}
+	if len(key) > MaxKeySizeInBytes {
+		return errors.KeyTooLargeErr
+	}
+	return transaction.batch.Delete(key)
+}
+
 // Commit commits the ReadWriteTransaction.
 // Commit involves the following:
 // 1. Acquiring an executorLock to ensure that the transaction are sent to the TransactionExecutor in the order of their commitTimestamp.
@@ -91,16 +243,51 @@ func (transaction *ReadWriteTransaction) PutOrUpdate(key []byte, value []byte) e
 // 4. Passing a commit callback to the TimestampedBatch which is invoked when the entire batch is applied
 // 5. The commit callback informs the `commitTimestampMark` of Oracle that a transaction with `commitTimestamp` is done
 // More details on commitTimestamp are available in Oracle. Commits are executed serially and the details are available in TransactionExecutor.
-func (transaction *ReadWriteTransaction) Commit() (<-chan struct{}, error) {
+// With option.WALSyncPerBatch, the returned channel is notified only after the WAL is synced, so the transaction is durable
+// once the notification is received.
+// The returned channel receives nil if the transaction is applied, else the error that prevented the transaction from being
+// applied (refer to TransactionExecutor.Err). Commit returns errors.WritesStoppedErr without getting the commit timestamp,
+// if the TransactionExecutor has already stopped accepting writes.
+// The transaction is discarded when Commit returns, irrespective of the result (refer to Discard). Commit returns
+// errors.TxnDiscardedErr for a transaction that is already discarded (or committed): its beginTimestamp is finished, so
+// the committed transactions it must be checked against for conflicts may already be cleaned up.
+// Commit returns the error of a failed Get (refer to Get) without committing the transaction.
+func (transaction *ReadWriteTransaction) Commit() (<-chan error, error) {
+	return transaction.CommitWithContext(context.Background())
+}
+
+// CommitWithContext commits the ReadWriteTransaction, like Commit, but it returns ctx.Err() if the ctx is done before the
+// TimestampedBatch is submitted to the TransactionExecutor:
+// 1. If the ctx is done before the commit timestamp is assigned, the transaction is not committed.
+// 2. If the ctx is done after the commit timestamp is assigned, but before the TimestampedBatch is accepted by the
+// TransactionExecutor (the `batchChannel` is full), the commit timestamp is abandoned (refer to Oracle.abandonCommitTimestamp):
+// the transaction is not committed, and the commitTimestampMark does not wait for it.
+// Once the TimestampedBatch is submitted, the transaction is applied irrespective of the ctx; the clients can give up
+// waiting on the returned channel, but the transaction may still be applied.
+// CommitWithContext does not give up waiting for the executorLock.
+func (transaction *ReadWriteTransaction) CommitWithContext(ctx context.Context) (<-chan error, error) {
+	if transaction.discarded.Load() {
+		return nil, errors.TxnDiscardedErr
+	}
+	defer transaction.Discard()
+	if transaction.readErr != nil {
+		return nil, transaction.readErr
+	}
 	if transaction.batch.IsEmpty() {
 		return nil, errors.EmptyTransactionErr
 	}
+	if err := transaction.oracle.transactionExecutor.Err(); err != nil {
+		return nil, err
+	}
 
 	// Send the transaction to the executor in the increasing order of the commitTimestamp.
 	// If a commit with the commitTimestamp 102 is applied, it is assumed that the commit with commitTimestamp 101 is already available.
 	transaction.oracle.executorLock.Lock()
 	defer transaction.oracle.executorLock.Unlock()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	commitTimestamp, err := transaction.oracle.mayBeCommitTimestampFor(transaction)
 	if err != nil {
 		return nil, err
@@ -108,12 +295,42 @@ func (transaction *ReadWriteTransaction) Commit() (<-chan struct{}, error) {
 	commitCallback := func() {
 		transaction.oracle.commitTimestampMark.Finish(commitTimestamp)
 	}
-	return transaction.oracle.transactionExecutor.Submit(transaction.batch.ToTimestampedBatch(commitTimestamp, commitCallback)), nil
+	done, err := transaction.oracle.transactionExecutor.SubmitWithContext(
+		ctx,
+		transaction.batch.ToTimestampedBatch(commitTimestamp, commitCallback),
+	)
+	if err != nil {
+		transaction.oracle.abandonCommitTimestamp(commitTimestamp)
+		return nil, err
+	}
+	return done, nil
 }
 
-// FinishBeginTimestampForReadWriteTransaction indicates the end of ReadWriteTransaction.
+// Discard indicates the end of ReadWriteTransaction, and drops all the changes of the transaction that are not committed (Rollback).
 // It is used to indicate the TransactionTimestampMark inside Oracle that all the transactions upto a given `beginTimestamp`
 // are done. (More on this in Oracle).
-func (transaction *ReadWriteTransaction) FinishBeginTimestampForReadWriteTransaction() {
+// Discard can be called more than once, only the first call finishes the beginTimestamp and clears the Batch.
+// A discarded transaction can not be written to or committed (refer to errors.TxnDiscardedErr).
+// Commit finishes the beginTimestamp before the Batch is submitted, so calling Discard after Commit does not roll back
+// (or clear) the committed changes.
+func (transaction *ReadWriteTransaction) Discard() {
+	if transaction.finishBeginTimestamp() {
+		transaction.batch.clear()
+	}
+}
+
+// finishBeginTimestamp marks the transaction as discarded and finishes its beginTimestamp, if the transaction is not
+// already discarded. It returns true if the transaction is discarded by this call.
+func (transaction *ReadWriteTransaction) finishBeginTimestamp() bool {
+	if !transaction.discarded.CompareAndSwap(false, true) {
+		return false
+	}
+	stopWatchingForLeak(transaction.leakTimer)
 	transaction.oracle.finishBeginTimestampForReadWriteTransaction(transaction)
+	return true
+}
+
+// FinishBeginTimestampForReadWriteTransaction indicates the end of ReadWriteTransaction. It is the same as Discard.
+func (transaction *ReadWriteTransaction) FinishBeginTimestampForReadWriteTransaction() {
+	transaction.Discard()
 }
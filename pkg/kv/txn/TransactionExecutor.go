@@ -1,10 +1,22 @@
 package txn
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 	"tinydb/pkg/kv"
 	"tinydb/pkg/kv/mvcc"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/txn/errors"
 )
 
+// batchQueueSize is the number of TimestampedBatches that can wait in the `batchChannel` to be applied.
+// It is also the largest possible size of a group (refer to spin).
+const batchQueueSize = 256
+
 // TransactionExecutor represents an implementation of [Singular Update Queue](https://martinfowler.com/articles/patterns-of-distributed-systems/singular-update-queue.html).
 // TransactionExecutor applies all the commits sequentially.
 //
@@ -12,19 +24,60 @@ import (
 // Anytime a ReadWriteTransaction is ready to commit, its TimestampedBatch is sent to the TransactionExecutor via Submit() method.
 // TransactionExecutor converts all the Keys present in the TimestampedBatch to mvcc.VersionedKey and Value to mvcc.Value and
 // applies all these mvcc.VersionedKey/mvcc.Value pairs to the kv.Workspace.
+// TransactionExecutor performs group commit: all the TimestampedBatches waiting in the `batchChannel` are applied together
+// as a group, with a single write to the WAL (and a single sync).
+// TransactionExecutor also syncs the WAL as per the option.WALSyncPolicy of the kv.Workspace. Since the WAL is written
+// only by this goroutine, the WAL is synced by this goroutine as well (even on an interval).
+// If a group can not be applied (or the WAL can not be synced), the TransactionExecutor stops accepting writes: the
+// TimestampedBatches of the failed group, and of all the later groups, are marked applied with the error (refer to Err).
+// The commit callbacks of such TimestampedBatches are not invoked, so their commit timestamps are never finished: the
+// key/value pairs of a failed group may already be in the active memtable, and must not become visible to the readers.
+// lastAppliedTimestamp is the commit timestamp of the last group that was applied (refer to readableTimestamp), and
+// writesStoppedChannel is closed when the TransactionExecutor stops accepting writes.
+// stopped is set by Stop under the submitLock, and the TimestampedBatches are submitted while holding the submitLock for
+// reading, so no TimestampedBatch is sent to the `batchChannel` after Stop closes it.
 type TransactionExecutor struct {
-	batchChannel chan TimestampedBatch
-	stopChannel  chan struct{}
-	workspace    *kv.Workspace
+	batchChannel         chan TimestampedBatch
+	stopChannel          chan struct{}
+	stoppedChannel       chan struct{}
+	writesStoppedChannel chan struct{}
+	workspace            *kv.Workspace
+	totalGroups          atomic.Uint64
+	totalBatches         atomic.Uint64
+	maxGroupSize         atomic.Uint64
+	lastAppliedTimestamp atomic.Uint64
+	writeErr             atomic.Pointer[error]
+	submitLock           sync.RWMutex
+	stopped              bool
+}
+
+// ExecutorMetrics represents the group commit metrics of the TransactionExecutor.
+// TotalGroups is the number of groups applied, TotalBatches is the number of TimestampedBatches applied across all the groups,
+// and MaxGroupSize is the number of TimestampedBatches in the largest group.
+type ExecutorMetrics struct {
+	TotalGroups  uint64
+	TotalBatches uint64
+	MaxGroupSize uint64
+}
+
+// AverageGroupSize returns the average number of TimestampedBatches per group.
+func (metrics ExecutorMetrics) AverageGroupSize() float64 {
+	if metrics.TotalGroups == 0 {
+		return 0
+	}
+	return float64(metrics.TotalBatches) / float64(metrics.TotalGroups)
 }
 
 // NewTransactionExecutor creates a new instance of TransactionExecutor. It is called once in the entire application.
 func NewTransactionExecutor(workspace *kv.Workspace) *TransactionExecutor {
 	transactionExecutor := &TransactionExecutor{
-		batchChannel: make(chan TimestampedBatch),
-		stopChannel:  make(chan struct{}),
-		workspace:    workspace,
+		batchChannel:         make(chan TimestampedBatch, batchQueueSize),
+		stopChannel:          make(chan struct{}),
+		stoppedChannel:       make(chan struct{}),
+		writesStoppedChannel: make(chan struct{}),
+		workspace:            workspace,
 	}
+	transactionExecutor.lastAppliedTimestamp.Store(workspace.LastCommitTimestamp())
 	go transactionExecutor.spin()
 	return transactionExecutor
 }
@@ -32,49 +85,231 @@ func NewTransactionExecutor(workspace *kv.Workspace) *TransactionExecutor {
 // Submit submits the TimestampedBatch to TransactionExecutor.
 // Anytime a ReadWriteTransaction is ready to commit, its TimestampedBatch is sent to the TransactionExecutor via Submit() method.
 // It also returns a doneChannel that the clients of the Commit() method of the ReadWriteTransaction can wait on to
-// get notified when the transaction is applied.
-func (executor *TransactionExecutor) Submit(batch TimestampedBatch) <-chan struct{} {
-	executor.batchChannel <- batch
+// get notified when the transaction is applied. The doneChannel receives nil if the TimestampedBatch is applied, else the error.
+// The doneChannel receives errors.ExecutorStoppedErr if the TransactionExecutor is stopped.
+func (executor *TransactionExecutor) Submit(batch TimestampedBatch) <-chan error {
+	if _, err := executor.SubmitWithContext(context.Background(), batch); err != nil {
+		executor.markApplied(batch, err)
+	}
 	return batch.doneChannel
 }
 
-// Stop stops the TransactionExecutor.
+// SubmitWithContext submits the TimestampedBatch to TransactionExecutor, like Submit, but it gives up waiting for a place
+// in the `batchChannel` when the ctx is done. It returns ctx.Err() if the TimestampedBatch is not submitted; such a
+// TimestampedBatch is never applied and its commit callback is never invoked.
+// It returns errors.ExecutorStoppedErr (without submitting the TimestampedBatch) if the TransactionExecutor is stopped.
+func (executor *TransactionExecutor) SubmitWithContext(ctx context.Context, batch TimestampedBatch) (<-chan error, error) {
+	executor.submitLock.RLock()
+	defer executor.submitLock.RUnlock()
+
+	if executor.stopped {
+		return nil, errors.ExecutorStoppedErr
+	}
+	select {
+	case executor.batchChannel <- batch:
+		return batch.doneChannel, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Err returns the error that stopped the TransactionExecutor from accepting writes, nil if the TransactionExecutor accepts writes.
+// The error wraps errors.WritesStoppedErr and the failure to apply a group (or to sync the WAL).
+// Once the TransactionExecutor stops accepting writes, it does not accept them again: the state of the WAL is unknown,
+// so the database must be reopened (which recovers the WAL) to accept writes.
+func (executor *TransactionExecutor) Err() error {
+	if err := executor.writeErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// readableTimestamp returns the commit timestamp of the last group that was applied and true, if the TransactionExecutor
+// has stopped accepting writes; (0, false) otherwise.
+// The key/value pairs with a commit timestamp greater than the readableTimestamp belong to a failed group (or a later
+// group that was not applied), so the transactions must not read beyond it (refer to Oracle.beginTimestampWithContext).
+func (executor *TransactionExecutor) readableTimestamp() (uint64, bool) {
+	if executor.Err() == nil {
+		return 0, false
+	}
+	return executor.lastAppliedTimestamp.Load(), true
+}
+
+// writesStopped returns a channel that is closed when the TransactionExecutor stops accepting writes.
+func (executor *TransactionExecutor) writesStopped() <-chan struct{} {
+	return executor.writesStoppedChannel
+}
+
+// Metrics returns the ExecutorMetrics.
+func (executor *TransactionExecutor) Metrics() ExecutorMetrics {
+	return ExecutorMetrics{
+		TotalGroups:  executor.totalGroups.Load(),
+		TotalBatches: executor.totalBatches.Load(),
+		MaxGroupSize: executor.maxGroupSize.Load(),
+	}
+}
+
+// Stop stops the TransactionExecutor. The TimestampedBatches that are waiting in the `batchChannel` are applied (drained)
+// before the TransactionExecutor stops, and Stop returns after all of them are applied.
+// No TimestampedBatch can be submitted after Stop (refer to errors.ExecutorStoppedErr). Stop can be called more than once,
+// only the first call stops the TransactionExecutor.
 func (executor *TransactionExecutor) Stop() {
+	executor.submitLock.Lock()
+	if executor.stopped {
+		executor.submitLock.Unlock()
+		return
+	}
+	executor.stopped = true
+	executor.submitLock.Unlock()
+
 	executor.stopChannel <- struct{}{}
+	<-executor.stoppedChannel
 }
 
 // spin is invoked as a single goroutine [`go spin()`] and it reads either an event from `stopChannel` or a TimestampedBatch from the `batchChannel`.
-// On receiving a TimestampedBatch, it converts all the Keys present in the TimestampedBatch to mvcc.VersionedKey and Value to mvcc.Value and
-// applies all these mvcc.VersionedKey/mvcc.Value pairs to the kv.Workspace.
+// On receiving an event from `stopChannel`, it applies all the TimestampedBatches waiting in the `batchChannel` before returning.
+// On receiving a TimestampedBatch, it drains all the other TimestampedBatches that are waiting in the `batchChannel`
+// without blocking, and applies all of them as a group.
+// With option.WALSyncOnInterval, spin also syncs the WAL on every tick of the syncTicker.
 func (executor *TransactionExecutor) spin() {
+	var syncTick <-chan time.Time
+	options := executor.workspace.Options()
+	if options.WALSyncPolicy == option.WALSyncOnInterval {
+		syncTicker := time.NewTicker(options.WALSyncInterval)
+		defer syncTicker.Stop()
+		syncTick = syncTicker.C
+	}
 	for {
 		select {
 		case timestampedBatch := <-executor.batchChannel:
-			executor.apply(timestampedBatch)
-			executor.markApplied(timestampedBatch)
+			executor.applyGroupWith(timestampedBatch)
+		case <-syncTick:
+			executor.syncWAL()
 		case <-executor.stopChannel:
+			for len(executor.batchChannel) > 0 {
+				executor.applyGroupWith(<-executor.batchChannel)
+			}
 			close(executor.batchChannel)
+			close(executor.stoppedChannel)
 			return
 		}
 	}
 }
 
-// apply converts all the Keys present in the TimestampedBatch to mvcc.VersionedKey and Value to mvcc.Value and
-// applies all these mvcc.VersionedKey/mvcc.Value pairs to the kv.Workspace.
-// After all the key/value pairs are applied, the commit callback is invoked.
-func (executor *TransactionExecutor) apply(timestampedBatch TimestampedBatch) {
-	for _, keyValuePair := range timestampedBatch.AllPairs() {
-		//TODO: Handle error
-		executor.workspace.PutOrUpdate(
-			mvcc.NewVersionedKey(keyValuePair.getKey(), timestampedBatch.timestamp),
-			mvcc.NewValue(keyValuePair.getValue()),
-		)
+// applyGroupWith applies the timestampedBatch and all the TimestampedBatches waiting in the `batchChannel` as a group,
+// and marks all of them applied.
+// The group is not applied if the TransactionExecutor has stopped accepting writes. The commit callbacks are invoked
+// only if the group is applied, so the commit timestamps of a group that fails (or is not applied) are never finished,
+// and its key/value pairs never become visible to the new transactions.
+func (executor *TransactionExecutor) applyGroupWith(timestampedBatch TimestampedBatch) {
+	group := executor.drain(timestampedBatch)
+	err := executor.Err()
+	if err == nil {
+		if applyErr := executor.apply(group); applyErr != nil {
+			err = executor.stopAcceptingWrites(applyErr)
+		}
+	}
+	if err == nil {
+		executor.lastAppliedTimestamp.Store(group[len(group)-1].timestamp)
+		for _, batch := range group {
+			batch.commitCallback()
+		}
+	}
+	for _, batch := range group {
+		executor.markApplied(batch, err)
+	}
+}
+
+// drain returns a group that contains the timestampedBatch and all the TimestampedBatches waiting in the `batchChannel`.
+// The TimestampedBatches of the group are in the increasing order of their commit timestamp, which is the order in which
+// they are applied and their commit callbacks are invoked.
+func (executor *TransactionExecutor) drain(timestampedBatch TimestampedBatch) []TimestampedBatch {
+	group := []TimestampedBatch{timestampedBatch}
+	for len(group) < batchQueueSize {
+		select {
+		case batch := <-executor.batchChannel:
+			group = append(group, batch)
+		default:
+			return sortedByTimestamp(group)
+		}
+	}
+	return sortedByTimestamp(group)
+}
+
+// sortedByTimestamp sorts the group in the increasing order of the commit timestamp.
+func sortedByTimestamp(group []TimestampedBatch) []TimestampedBatch {
+	sort.SliceStable(group, func(i, j int) bool {
+		return group[i].timestamp < group[j].timestamp
+	})
+	return group
+}
+
+// apply converts all the Keys present in the group of TimestampedBatches to mvcc.VersionedKey and Value to mvcc.Value and
+// applies all these mvcc.VersionedKey/mvcc.Value pairs to the kv.Workspace, with a single write to the WAL.
+// A key that is deleted in a TimestampedBatch is applied with mvcc.NewDeletedValue (a tombstone), which is what
+// kv.Workspace.Delete writes; this keeps the deletes in the same single write to the WAL as the rest of the group.
+// With option.WALSyncPerBatch, the WAL is synced once after all the key/value pairs of the group are applied.
+// apply returns the error if the key/value pairs can not be applied to the kv.Workspace, or the WAL can not be synced.
+func (executor *TransactionExecutor) apply(group []TimestampedBatch) error {
+	var pairs []mvcc.VersionedKeyValue
+	for _, timestampedBatch := range group {
+		for _, keyValuePair := range timestampedBatch.AllPairs() {
+			value := mvcc.NewValue(keyValuePair.getValue())
+			if keyValuePair.isDeleted() {
+				value = mvcc.NewDeletedValue()
+			}
+			pairs = append(pairs, mvcc.VersionedKeyValue{
+				Key:   mvcc.NewVersionedKey(keyValuePair.getKey(), timestampedBatch.timestamp),
+				Value: value,
+			})
+		}
+	}
+	if err := executor.workspace.PutOrUpdateAll(pairs); err != nil {
+		return err
+	}
+	if executor.workspace.Options().WALSyncPolicy == option.WALSyncPerBatch {
+		if err := executor.workspace.SyncWAL(); err != nil {
+			return err
+		}
+	}
+	executor.recordGroupSize(uint64(len(group)))
+	return nil
+}
+
+// recordGroupSize records the size of a group in the ExecutorMetrics.
+func (executor *TransactionExecutor) recordGroupSize(groupSize uint64) {
+	executor.totalGroups.Add(1)
+	executor.totalBatches.Add(groupSize)
+	if groupSize > executor.maxGroupSize.Load() {
+		executor.maxGroupSize.Store(groupSize)
+	}
+}
+
+// syncWAL syncs the WAL of the kv.Workspace (with option.WALSyncOnInterval), and stops accepting writes if the sync fails.
+func (executor *TransactionExecutor) syncWAL() {
+	if executor.Err() != nil {
+		return
+	}
+	if err := executor.workspace.SyncWAL(); err != nil {
+		executor.stopAcceptingWrites(err)
+	}
+}
+
+// stopAcceptingWrites records the failure (wrapped with errors.WritesStoppedErr) that stops the TransactionExecutor from
+// accepting writes, closes the writesStoppedChannel (only for the first failure), and returns the recorded error.
+func (executor *TransactionExecutor) stopAcceptingWrites(failure error) error {
+	err := fmt.Errorf("%w: %w", errors.WritesStoppedErr, failure)
+	if executor.writeErr.CompareAndSwap(nil, &err) {
+		close(executor.writesStoppedChannel)
 	}
-	timestampedBatch.commitCallback()
+	//TODO: Removes println in favor of logging
+	println("stopped accepting writes after the error ", failure.Error())
+	return executor.Err()
 }
 
-// markApplied sends a notification to the doneChannel and closes the channel to indicate that the transaction is applied.
-func (executor *TransactionExecutor) markApplied(batch TimestampedBatch) {
-	batch.doneChannel <- struct{}{}
+// markApplied sends the result (nil or the error) to the doneChannel and closes the channel to indicate that the
+// transaction is applied (or could not be applied).
+func (executor *TransactionExecutor) markApplied(batch TimestampedBatch, err error) {
+	batch.doneChannel <- err
 	close(batch.doneChannel)
 }
@@ -1,11 +1,16 @@
 package txn
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 	"tinydb/pkg/kv"
 	"tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
+	txnErrors "tinydb/pkg/kv/txn/errors"
 )
 
 func TestExecutesABatch(t *testing.T) {
@@ -22,11 +27,11 @@ func TestExecutesABatch(t *testing.T) {
 	doneChannel := executor.Submit(batch.ToTimestampedBatch(1, noCallback))
 	<-doneChannel
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 1))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Snapshot"), valueWithVersion.ValueSlice())
 }
@@ -46,11 +51,11 @@ func TestExecutesABatchAnInvokesCommitCallback(t *testing.T) {
 	doneChannel := executor.Submit(batch.ToTimestampedBatch(1, commitCallback))
 	<-doneChannel
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("commit"), 1))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("commit"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("applied"), valueWithVersion.ValueSlice())
 }
@@ -77,19 +82,19 @@ func TestExecutes2Batches(t *testing.T) {
 	doneChannel = executor.Submit(anotherBatch.ToTimestampedBatch(2, noCallback))
 	<-doneChannel
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 1))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Snapshot"), valueWithVersion.ValueSlice())
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk drive"), valueWithVersion.ValueSlice())
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 3))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 3))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Serialized Snapshot"), valueWithVersion.ValueSlice())
 }
@@ -111,11 +116,212 @@ func TestExecutesABatchAndStops(t *testing.T) {
 
 	executor.Stop()
 
-	valueWithVersion, ok := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
 
-	valueWithVersion, ok = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 1))
+	valueWithVersion, ok, _ = workspace.Get(mvcc.NewVersionedKey([]byte("isolation"), 1))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Snapshot"), valueWithVersion.ValueSlice())
 }
+
+func TestExecutesABatchAndSyncsTheWALBeforeMarkingItApplied(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir()).SetWALSyncPolicy(option.WALSyncPerBatch)
+	workspace, _ := kv.NewWorkspace(options)
+
+	executor := NewTransactionExecutor(workspace)
+
+	batch := NewBatch()
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+
+	<-executor.Submit(batch.ToTimestampedBatch(1, func() {}))
+	executor.Stop()
+	workspace.Stop()
+
+	recovered, err := kv.Open(options)
+	assert.Nil(t, err)
+	defer recovered.Stop()
+
+	valueWithVersion, ok, _ := recovered.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+}
+
+func TestExecutesBatchesAndSyncsTheWALOnAnInterval(t *testing.T) {
+	options := option.DefaultOptions().
+		SetDbDirectory(t.TempDir()).
+		SetWALSyncPolicy(option.WALSyncOnInterval).
+		SetWALSyncInterval(time.Millisecond)
+	workspace, _ := kv.NewWorkspace(options)
+	defer workspace.Stop()
+
+	executor := NewTransactionExecutor(workspace)
+	defer executor.Stop()
+
+	for version := uint64(1); version <= 3; version++ {
+		batch := NewBatch()
+		_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+		<-executor.Submit(batch.ToTimestampedBatch(version, func() {}))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 3))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(3), valueWithVersion.Version)
+}
+
+func TestAppliesAllTheQueuedBatchesAsAGroup(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	executor := &TransactionExecutor{
+		batchChannel: make(chan TimestampedBatch, batchQueueSize),
+		stopChannel:  make(chan struct{}),
+		workspace:    workspace,
+	}
+	var committed []uint64
+	timestampedBatchOf := func(key string, timestamp uint64) TimestampedBatch {
+		batch := NewBatch()
+		_ = batch.Add([]byte(key), []byte(key))
+		return batch.ToTimestampedBatch(timestamp, func() {
+			committed = append(committed, timestamp)
+		})
+	}
+	executor.batchChannel <- timestampedBatchOf("SSD", 2)
+	executor.batchChannel <- timestampedBatchOf("NVMe", 3)
+
+	executor.applyGroupWith(timestampedBatchOf("HDD", 1))
+	assert.Equal(t, []uint64{1, 2, 3}, committed)
+
+	for key, timestamp := range map[string]uint64{"HDD": 1, "SSD": 2, "NVMe": 3} {
+		valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte(key), timestamp))
+		assert.Equal(t, true, ok)
+		assert.Equal(t, []byte(key), valueWithVersion.ValueSlice())
+	}
+	assert.Equal(t, ExecutorMetrics{TotalGroups: 1, TotalBatches: 3, MaxGroupSize: 3}, executor.Metrics())
+	assert.Equal(t, float64(3), executor.Metrics().AverageGroupSize())
+}
+
+func TestExecutesConcurrentlySubmittedBatches(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	executor := NewTransactionExecutor(workspace)
+	defer executor.Stop()
+
+	var doneChannels []<-chan error
+	for timestamp := uint64(1); timestamp <= 20; timestamp++ {
+		batch := NewBatch()
+		_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+		doneChannels = append(doneChannels, executor.Submit(batch.ToTimestampedBatch(timestamp, func() {})))
+	}
+	for _, doneChannel := range doneChannels {
+		assert.Nil(t, <-doneChannel)
+	}
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 20))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(20), valueWithVersion.Version)
+	assert.Equal(t, uint64(20), executor.Metrics().TotalBatches)
+}
+
+func TestExecutesABatchWithADeletedKey(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+
+	executor := NewTransactionExecutor(workspace)
+	defer executor.Stop()
+
+	batch := NewBatch()
+	_ = batch.Delete([]byte("HDD"))
+
+	noCallback := func() {}
+	<-executor.Submit(batch.ToTimestampedBatch(2, noCallback))
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 2))
+	assert.Equal(t, false, ok)
+
+	valueWithVersion, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
+}
+
+func TestAppliesTheQueuedBatchesBeforeStopping(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	executor := NewTransactionExecutor(workspace)
+
+	for timestamp := uint64(1); timestamp <= 10; timestamp++ {
+		batch := NewBatch()
+		_ = batch.Add([]byte(fmt.Sprintf("key-%d", timestamp)), []byte("value"))
+		executor.Submit(batch.ToTimestampedBatch(timestamp, func() {}))
+	}
+	executor.Stop()
+
+	for timestamp := uint64(1); timestamp <= 10; timestamp++ {
+		_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte(fmt.Sprintf("key-%d", timestamp)), timestamp))
+		assert.Equal(t, true, ok)
+	}
+}
+
+func TestDoesNotSubmitABatchAfterStopping(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	executor := NewTransactionExecutor(workspace)
+	executor.Stop()
+	executor.Stop()
+
+	batch := NewBatch()
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+	assert.Equal(t, txnErrors.ExecutorStoppedErr, <-executor.Submit(batch.ToTimestampedBatch(1, func() {})))
+
+	anotherBatch := NewBatch()
+	_ = anotherBatch.Add([]byte("SSD"), []byte("Solid state drive"))
+	_, err := executor.SubmitWithContext(context.Background(), anotherBatch.ToTimestampedBatch(2, func() {}))
+	assert.Equal(t, txnErrors.ExecutorStoppedErr, err)
+
+	_, ok, _ := workspace.Get(mvcc.NewVersionedKey([]byte("HDD"), 1))
+	assert.Equal(t, false, ok)
+}
+
+func TestStopsAcceptingWritesAfterAFailureToApplyABatch(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	executor := NewTransactionExecutor(workspace)
+	defer executor.Stop()
+
+	_ = workspace.Close()
+
+	batch := NewBatch()
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+
+	callbacks := 0
+	err := <-executor.Submit(batch.ToTimestampedBatch(1, func() { callbacks++ }))
+	assert.True(t, errors.Is(err, txnErrors.WritesStoppedErr))
+	assert.Equal(t, err, executor.Err())
+
+	anotherBatch := NewBatch()
+	_ = anotherBatch.Add([]byte("SSD"), []byte("Solid state drive"))
+
+	err = <-executor.Submit(anotherBatch.ToTimestampedBatch(2, func() { callbacks++ }))
+	assert.True(t, errors.Is(err, txnErrors.WritesStoppedErr))
+	assert.Equal(t, 0, callbacks)
+	assert.Equal(t, uint64(0), executor.Metrics().TotalGroups)
+}
+
+func TestAcceptsWritesWithoutAFailure(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	executor := NewTransactionExecutor(workspace)
+	defer executor.Stop()
+
+	batch := NewBatch()
+	_ = batch.Add([]byte("HDD"), []byte("Hard disk"))
+
+	assert.Nil(t, <-executor.Submit(batch.ToTimestampedBatch(1, func() {})))
+	assert.Nil(t, executor.Err())
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package txn
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"tinydb/pkg/kv"
+	"tinydb/pkg/kv/log"
+	"tinydb/pkg/kv/option"
+	txnErrors "tinydb/pkg/kv/txn/errors"
+)
+
+// The WAL is a FIFO in the following test: the writes to a FIFO succeed, but fsync on a FIFO fails.
+func TestDoesNotExposeTheKeysOfACommitWhoseWALSyncFailsToNewTransactions(t *testing.T) {
+	directory := t.TempDir()
+	assert.Nil(t, syscall.Mkfifo(log.FilePath(0, directory), 0644))
+
+	workspace, err := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetWALSyncPolicy(option.WALSyncPerBatch))
+	assert.Nil(t, err)
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	defer oracle.Stop()
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	done, err := transaction.Commit()
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(<-done, txnErrors.WritesStoppedErr))
+
+	readonlyTransaction := NewReadonlyTransaction(oracle)
+	defer readonlyTransaction.Discard()
+
+	_, ok, _ := readonlyTransaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, uint64(0), readonlyTransaction.beginTimestamp)
+}
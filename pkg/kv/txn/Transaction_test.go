@@ -1,12 +1,17 @@
 package txn
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
+	"time"
 	"tinydb/pkg/kv"
 	mvcc "tinydb/pkg/kv/mvcc"
 	"tinydb/pkg/kv/option"
 	"tinydb/pkg/kv/txn/errors"
+	"tinydb/pkg/kv/vlog"
+	vlogErrors "tinydb/pkg/kv/vlog/errors"
 )
 
 func TestGetsANonExistingKeyInAReadonlyTransaction(t *testing.T) {
@@ -14,7 +19,7 @@ func TestGetsANonExistingKeyInAReadonlyTransaction(t *testing.T) {
 	defer workspace.RemoveAllWAL()
 
 	transaction := NewReadonlyTransaction(NewOracle(NewTransactionExecutor(workspace)))
-	_, ok := transaction.Get([]byte("non-existing"))
+	_, ok, _ := transaction.Get([]byte("non-existing"))
 
 	assert.Equal(t, false, ok)
 }
@@ -31,7 +36,7 @@ func TestGetsAnExistingKeyInAReadonlyTransaction(t *testing.T) {
 	oracle.commitTimestampMark.Finish(1)
 
 	transaction := NewReadonlyTransaction(oracle)
-	valueWithVersion, ok := transaction.Get([]byte("HDD"))
+	valueWithVersion, ok, _ := transaction.Get([]byte("HDD"))
 
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
@@ -53,7 +58,7 @@ func TestCommitsAnEmptyReadWriteTransaction(t *testing.T) {
 }
 
 func TestAttemptsToPutDuplicateKeysInATransaction(t *testing.T) {
-	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory("."))
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(".").SetRejectDuplicateKeys(true))
 	defer workspace.RemoveAllWAL()
 
 	oracle := NewOracle(NewTransactionExecutor(workspace))
@@ -81,19 +86,17 @@ func TestGetsAnExistingKeyInAReadWriteTransaction(t *testing.T) {
 
 	anotherTransaction := NewReadWriteTransaction(oracle)
 	_ = anotherTransaction.PutOrUpdate([]byte("SSD"), []byte("Solid state disk"))
-	done, _ = transaction.Commit()
-	<-done
 
 	readonlyTransaction := NewReadonlyTransaction(oracle)
 
-	valueWithVersion, ok := readonlyTransaction.Get([]byte("HDD"))
+	valueWithVersion, ok, _ := readonlyTransaction.Get([]byte("HDD"))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, []byte("Hard disk"), valueWithVersion.ValueSlice())
 
-	_, ok = readonlyTransaction.Get([]byte("SSD"))
+	_, ok, _ = readonlyTransaction.Get([]byte("SSD"))
 	assert.Equal(t, false, ok)
 
-	_, ok = readonlyTransaction.Get([]byte("non-existing"))
+	_, ok, _ = readonlyTransaction.Get([]byte("non-existing"))
 	assert.Equal(t, false, ok)
 }
 
@@ -104,7 +107,7 @@ func TestGetsTheValueFromAKeyInAReadWriteTransactionFromBatch(t *testing.T) {
 	transaction := NewReadWriteTransaction(NewOracle(NewTransactionExecutor(workspace)))
 	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
 
-	value, ok := transaction.Get([]byte("HDD"))
+	value, ok, _ := transaction.Get([]byte("HDD"))
 	assert.Equal(t, true, ok)
 	assert.Equal(t, transaction.beginTimestamp, value.Version)
 	assert.Equal(t, []byte("Hard disk"), value.ValueSlice())
@@ -125,9 +128,7 @@ func TestTracksReadsInAReadWriteTransaction(t *testing.T) {
 	<-done
 
 	assert.Equal(t, 1, len(transaction.reads))
-	key := transaction.reads[0]
-
-	assert.Equal(t, []byte("SSD"), key)
+	assert.Equal(t, true, transaction.reads.contains(fingerprintOf([]byte("SSD"))))
 }
 
 func TestDoesNotTrackReadsInAReadWriteTransactionIfKeysAreReadFromTheBatch(t *testing.T) {
@@ -143,3 +144,432 @@ func TestDoesNotTrackReadsInAReadWriteTransactionIfKeysAreReadFromTheBatch(t *te
 
 	assert.Equal(t, 0, len(transaction.reads))
 }
+
+func allKeysOf(iterator *Iterator) []string {
+	var keys []string
+	for ; iterator.IsValid(); iterator.Next() {
+		keys = append(keys, string(iterator.Key()))
+	}
+	return keys
+}
+
+func TestScansTheKeysVisibleAtTheBeginTimestampInAReadonlyTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state")))
+	_ = workspace.Delete(mvcc.NewVersionedKey([]byte("SSD"), 2))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("NVMe"), 2), mvcc.NewValue([]byte("Non volatile memory")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("Tape"), 3), mvcc.NewValue([]byte("Tape drive")))
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	oracle.nextTimestamp = 3
+	oracle.commitTimestampMark.Finish(2)
+
+	transaction := NewReadonlyTransaction(oracle)
+	iterator := transaction.Scan(nil, nil)
+	defer iterator.Close()
+
+	assert.Equal(t, []string{"HDD", "NVMe"}, allKeysOf(iterator))
+}
+
+func TestScansAPrefixInAReadonlyTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("disk:hdd"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("disk:ssd"), 1), mvcc.NewValue([]byte("Solid state")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("disk;"), 1), mvcc.NewValue([]byte("Not a disk")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("tape:lto"), 1), mvcc.NewValue([]byte("Tape")))
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	oracle.nextTimestamp = 2
+	oracle.commitTimestampMark.Finish(1)
+
+	transaction := NewReadonlyTransaction(oracle)
+	iterator := transaction.ScanPrefix([]byte("disk:"))
+	defer iterator.Close()
+
+	assert.Equal(t, []string{"disk:hdd", "disk:ssd"}, allKeysOf(iterator))
+}
+
+func TestScansTheKeysFromTheBatchAndTheWorkspaceInAReadWriteTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("SSD"), 1), mvcc.NewValue([]byte("Solid state")))
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	oracle.nextTimestamp = 2
+	oracle.commitTimestampMark.Finish(1)
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("SSD"), []byte("Solid state drive"))
+	_ = transaction.PutOrUpdate([]byte("NVMe"), []byte("Non volatile memory"))
+
+	iterator := transaction.Scan(nil, nil)
+	defer iterator.Close()
+
+	var keyValues []string
+	for ; iterator.IsValid(); iterator.Next() {
+		keyValues = append(keyValues, string(iterator.Key())+"="+string(iterator.Value().ValueSlice()))
+	}
+	assert.Equal(t, []string{"HDD=Hard disk", "NVMe=Non volatile memory", "SSD=Solid state drive"}, keyValues)
+	assert.Equal(t, []KeyRange{newKeyRange(nil, nil)}, transaction.readRanges)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("disk;"), prefixEnd([]byte("disk:")))
+	assert.Equal(t, []byte{0x01}, prefixEnd([]byte{0x00, 0xff}))
+	assert.Nil(t, prefixEnd([]byte{0xff, 0xff}))
+	assert.Nil(t, prefixEnd(nil))
+}
+
+func TestGetsADeletedKeyInTheSameReadWriteTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	_ = workspace.PutOrUpdate(mvcc.NewVersionedKey([]byte("HDD"), 1), mvcc.NewValue([]byte("Hard disk")))
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	oracle.nextTimestamp = 2
+	oracle.commitTimestampMark.Finish(1)
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.Delete([]byte("HDD"))
+
+	_, ok, _ := transaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, 0, len(transaction.reads))
+
+	iterator := transaction.Scan(nil, nil)
+	defer iterator.Close()
+	assert.Equal(t, 0, len(allKeysOf(iterator)))
+}
+
+func TestCommitsADeleteInAReadWriteTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	done, _ := transaction.Commit()
+	<-done
+	transaction.FinishBeginTimestampForReadWriteTransaction()
+
+	transaction = NewReadWriteTransaction(oracle)
+	_ = transaction.Delete([]byte("HDD"))
+	done, _ = transaction.Commit()
+	<-done
+	transaction.FinishBeginTimestampForReadWriteTransaction()
+
+	readonlyTransaction := NewReadonlyTransaction(oracle)
+	_, ok, _ := readonlyTransaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+}
+
+func TestAttemptsToPutMoreKeysThanTheMaximumEntriesInATransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetMaxBatchEntries(1))
+	defer workspace.Stop()
+
+	transaction := NewReadWriteTransaction(NewOracle(NewTransactionExecutor(workspace)))
+	assert.Nil(t, transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk")))
+
+	err := transaction.PutOrUpdate([]byte("SSD"), []byte("Solid state"))
+	assert.Error(t, err)
+	assert.Equal(t, errors.TxnTooBigErr, err)
+}
+
+func TestOverwritesAKeyInAReadWriteTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	transaction := NewReadWriteTransaction(oracle)
+	assert.Nil(t, transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk")))
+	assert.Nil(t, transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk drive")))
+
+	value, ok, _ := transaction.Get([]byte("HDD"))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk drive"), value.ValueSlice())
+
+	assert.Nil(t, transaction.Delete([]byte("HDD")))
+	_, ok, _ = transaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+
+	assert.Nil(t, transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk (final)")))
+	done, _ := transaction.Commit()
+	<-done
+	transaction.FinishBeginTimestampForReadWriteTransaction()
+
+	readonlyTransaction := NewReadonlyTransaction(oracle)
+	value, ok, _ = readonlyTransaction.Get([]byte("HDD"))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("Hard disk (final)"), value.ValueSlice())
+}
+
+func TestDiscardsAReadonlyTransactionMoreThanOnce(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	oracle.nextTimestamp = 3
+	oracle.commitTimestampMark.Finish(2)
+
+	aTransaction := NewReadonlyTransaction(oracle)
+	anotherTransaction := NewReadonlyTransaction(oracle)
+
+	aTransaction.Discard()
+	aTransaction.Discard()
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, uint64(0), oracle.beginTimestampMark.DoneTill())
+
+	anotherTransaction.Discard()
+	assert.Eventually(t, func() bool {
+		return oracle.beginTimestampMark.DoneTill() == uint64(2)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDiscardsAReadWriteTransactionOnConflict(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	aTransaction := NewReadWriteTransaction(oracle)
+	_ = aTransaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+
+	anotherTransaction := NewReadWriteTransaction(oracle)
+	anotherTransaction.Get([]byte("HDD"))
+	_ = anotherTransaction.PutOrUpdate([]byte("SSD"), []byte("Solid state"))
+
+	done, _ := aTransaction.Commit()
+	<-done
+
+	_, err := anotherTransaction.Commit()
+	assert.Equal(t, errors.ConflictErr, err)
+	assert.Equal(t, true, anotherTransaction.discarded.Load())
+
+	anotherTransaction.Discard()
+
+	readonlyTransaction := NewReadonlyTransaction(oracle)
+	readonlyTransaction.Discard()
+	assert.Eventually(t, func() bool {
+		return oracle.beginTimestampMark.DoneTill() == uint64(1)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestReportsATransactionThatIsNotDiscardedWithinTheDeadline(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()).SetTransactionLeakDeadline(10 * time.Millisecond))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	discardedTransaction := NewReadWriteTransaction(oracle)
+	discardedTransaction.Discard()
+
+	leakedTransaction := NewReadonlyTransaction(oracle)
+	defer leakedTransaction.Discard()
+
+	assert.Eventually(t, func() bool {
+		return oracle.LeakedTransactions() == 1
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, uint64(1), oracle.LeakedTransactions())
+}
+
+func TestGivesUpBeginningAReadonlyTransactionWhenTheContextIsDone(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(transaction)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := NewReadonlyTransactionWithContext(ctx, oracle)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	readonlyTransaction, err := NewReadonlyTransactionWithContext(context.Background(), oracle)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), readonlyTransaction.beginTimestamp)
+	readonlyTransaction.Discard()
+
+	assert.Eventually(t, func() bool {
+		return oracle.beginTimestampMark.DoneTill() == uint64(1)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestGivesUpBeginningAReadWriteTransactionWhenTheContextIsDone(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(transaction)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := oracle.BeginWithContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	anotherTransaction, err := oracle.BeginWithContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), anotherTransaction.beginTimestamp)
+	anotherTransaction.Discard()
+
+	readonlyTransaction, err := oracle.BeginReadonlyWithContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), readonlyTransaction.beginTimestamp)
+	readonlyTransaction.Discard()
+}
+
+func TestDoesNotCommitAReadWriteTransactionWhenTheContextIsDone(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+
+	transaction, err := NewReadWriteTransactionWithContext(context.Background(), oracle)
+	assert.Nil(t, err)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = transaction.CommitWithContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, true, transaction.discarded.Load())
+	assert.Equal(t, 0, oracle.CommittedTransactionLength())
+
+	readonlyTransaction := NewReadonlyTransaction(oracle)
+	defer readonlyTransaction.Discard()
+
+	_, ok, _ := readonlyTransaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+}
+
+func TestAbandonsTheCommitTimestampWhenTheContextIsDoneBeforeTheBatchIsSubmitted(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	// The executor does not spin, so the batch can never be submitted to its (unbuffered) `batchChannel`.
+	executor := &TransactionExecutor{
+		batchChannel: make(chan TimestampedBatch),
+		workspace:    workspace,
+	}
+	oracle := NewOracle(executor)
+
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := transaction.CommitWithContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, uint64(1), transaction.commitTimestamp)
+	assert.Equal(t, 0, oracle.CommittedTransactionLength())
+
+	assert.Eventually(t, func() bool {
+		return oracle.commitTimestampMark.DoneTill() == uint64(1)
+	}, time.Second, 5*time.Millisecond)
+
+	readonlyTransaction := NewReadonlyTransaction(oracle)
+	defer readonlyTransaction.Discard()
+
+	assert.Equal(t, uint64(1), readonlyTransaction.beginTimestamp)
+	_, ok, _ := readonlyTransaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+}
+
+func TestAttemptsToPutAKeyOrAValueLargerThanTheMaximumSizeInATransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	transaction := NewReadWriteTransaction(NewOracle(NewTransactionExecutor(workspace)))
+	assert.Equal(t, errors.KeyTooLargeErr, transaction.PutOrUpdate(make([]byte, MaxKeySizeInBytes+1), []byte("Hard disk")))
+	assert.Equal(t, errors.ValueTooLargeErr, transaction.PutOrUpdate([]byte("HDD"), make([]byte, MaxValueSizeInBytes+1)))
+	assert.Equal(t, errors.KeyTooLargeErr, transaction.Delete(make([]byte, MaxKeySizeInBytes+1)))
+
+	assert.Nil(t, transaction.PutOrUpdate(make([]byte, MaxKeySizeInBytes), []byte("Hard disk")))
+}
+
+func TestDoesNotCommitADiscardedReadWriteTransaction(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	transaction.Discard()
+
+	_, ok, _ := transaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, errors.TxnDiscardedErr, transaction.PutOrUpdate([]byte("SSD"), []byte("Solid state drive")))
+	assert.Equal(t, errors.TxnDiscardedErr, transaction.Delete([]byte("HDD")))
+
+	done, err := transaction.Commit()
+	assert.Nil(t, done)
+	assert.Equal(t, errors.TxnDiscardedErr, err)
+	assert.Equal(t, 0, oracle.CommittedTransactionLength())
+}
+
+func TestDoesNotCommitAReadWriteTransactionTwice(t *testing.T) {
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+
+	done, err := transaction.Commit()
+	assert.Nil(t, err)
+	assert.Nil(t, <-done)
+
+	_, err = transaction.Commit()
+	assert.Equal(t, errors.TxnDiscardedErr, err)
+}
+
+func TestDoesNotCommitAReadWriteTransactionThatFailsToReadAValue(t *testing.T) {
+	directory := t.TempDir()
+	workspace, _ := kv.NewWorkspace(option.DefaultOptions().SetDbDirectory(directory).SetValueThresholdInBytes(4))
+	defer workspace.Stop()
+
+	oracle := NewOracle(NewTransactionExecutor(workspace))
+	transaction := NewReadWriteTransaction(oracle)
+	_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	done, _ := transaction.Commit()
+	<-done
+
+	file, _ := os.OpenFile(vlog.FilePath(0, directory), os.O_RDWR, 0644)
+	_, _ = file.WriteAt([]byte("corrupt"), 4)
+	_ = file.Close()
+
+	anotherTransaction := NewReadWriteTransaction(oracle)
+	_, ok, err := anotherTransaction.Get([]byte("HDD"))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, vlogErrors.CorruptValueErr, err)
+
+	_ = anotherTransaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk drive"))
+	_, err = anotherTransaction.Commit()
+	assert.Equal(t, vlogErrors.CorruptValueErr, err)
+	assert.Equal(t, 1, oracle.CommittedTransactionLength())
+}
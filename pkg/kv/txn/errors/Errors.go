@@ -3,5 +3,11 @@ package errors
 import "errors"
 
 var ConflictErr = errors.New("transaction conflicts with other concurrent transaction, retry")
-var EmptyTransactionErr = errors.New("transaction is empty, invoke PutOrUpdate in a transaction before committing")
+var EmptyTransactionErr = errors.New("transaction is empty, invoke PutOrUpdate or Delete in a transaction before committing")
 var DuplicateKeyInBatchErr = errors.New("batch already contains the key")
+var TxnTooBigErr = errors.New("transaction exceeds the maximum number of entries or the maximum size of a batch")
+var TxnDiscardedErr = errors.New("transaction is discarded, begin a new transaction")
+var WritesStoppedErr = errors.New("writes are stopped after a failure to apply a batch, the database is read-only")
+var ExecutorStoppedErr = errors.New("transaction executor is stopped, no batch can be submitted")
+var KeyTooLargeErr = errors.New("key exceeds the maximum key size")
+var ValueTooLargeErr = errors.New("value exceeds the maximum value size")
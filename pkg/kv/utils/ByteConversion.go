@@ -2,26 +2,38 @@ package utils
 
 import (
 	"encoding/binary"
-	"reflect"
 	"unsafe"
 )
 
 const uint32Size = int(unsafe.Sizeof(uint32(0)))
+const uint64Size = int(unsafe.Sizeof(uint64(0)))
 
-func U32SliceToBytes(uint32s []uint32) []byte {
-	if len(uint32s) == 0 {
-		return nil
+// U32SliceToBytesLittleEndian encodes the uint32s one after the other, each in the little endian order.
+func U32SliceToBytesLittleEndian(uint32s []uint32) []byte {
+	bytes := make([]byte, 0, len(uint32s)*uint32Size)
+	for _, value := range uint32s {
+		bytes = binary.LittleEndian.AppendUint32(bytes, value)
 	}
-	var bytes []byte
-	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&bytes))
-	sliceHeader.Len = len(uint32s) * uint32Size
-	sliceHeader.Cap = sliceHeader.Len
-	sliceHeader.Data = uintptr(unsafe.Pointer(&uint32s[0]))
 	return bytes
 }
 
+// BytesToU32SliceLittleEndian decodes the uint32s encoded by U32SliceToBytesLittleEndian. The bytes need not be aligned.
+func BytesToU32SliceLittleEndian(bytes []byte) []uint32 {
+	uint32s := make([]uint32, len(bytes)/uint32Size)
+	for index := range uint32s {
+		uint32s[index] = binary.LittleEndian.Uint32(bytes[index*uint32Size:])
+	}
+	return uint32s
+}
+
 func U32ToBytesLittleEndian(value uint32) []byte {
 	var bytes [uint32Size]byte
 	binary.LittleEndian.PutUint32(bytes[:], value)
 	return bytes[:]
 }
+
+func U64ToBytesLittleEndian(value uint64) []byte {
+	var bytes [uint64Size]byte
+	binary.LittleEndian.PutUint64(bytes[:], value)
+	return bytes[:]
+}
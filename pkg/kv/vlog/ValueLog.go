@@ -0,0 +1,234 @@
+package vlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"tinydb/pkg/kv/vlog/errors"
+)
+
+const fileExtension = ".vlog"
+
+// checksumSize is the size of the checksum that precedes every value in a value log file.
+const checksumSize = uint32Size
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ValueLog is an append-only log of the large values, which separates them from their keys (WiscKey-style).
+// A large value is appended to the ValueLog once, and the LSM (the WAL, the memtables and the SSTables) stores only its
+// ValuePointer, so the value is not copied again by the flushes and the compactions.
+// The ValueLog is made of files named <fileId>.vlog in the directory. Values are appended to the active file (the one
+// with the largest fileId), and a new active file is created once the active file would exceed maxFileSizeInBytes.
+// Every entry of a value log file is the checksum of the value followed by the value.
+/*
+Structure of an entry.
++-----------------------+-------+
+| 4 bytes CRC32C        | Value |
++-----------------------+-------+
+*/
+// Append must be called from a single goroutine, while Read can be called concurrently with Append.
+// The lock protects the files, not their contents.
+// The space of the values that are overwritten (or deleted) is not reclaimed, the ValueLog does not garbage collect its files.
+type ValueLog struct {
+	directory          string
+	maxFileSizeInBytes uint64
+	lock               sync.RWMutex
+	files              map[uint32]*os.File
+	activeFile         *os.File
+	activeFileId       uint32
+	activeFileSize     uint64
+}
+
+// Open opens the ValueLog in the directory, with all its existing files. The file with the largest fileId becomes the
+// active file, and the values are appended after its existing entries. The active file is created lazily by Append,
+// so a ValueLog that never stores a value does not create any file.
+// It returns errors.FileSizeTooLargeErr if maxFileSizeInBytes exceeds math.MaxUint32, because a ValuePointer holds a
+// 32-bit offset.
+func Open(directory string, maxFileSizeInBytes uint64) (*ValueLog, error) {
+	if maxFileSizeInBytes > math.MaxUint32 {
+		return nil, errors.FileSizeTooLargeErr
+	}
+	fileIds, err := existingFileIds(directory)
+	if err != nil {
+		return nil, err
+	}
+	valueLog := &ValueLog{
+		directory:          directory,
+		maxFileSizeInBytes: maxFileSizeInBytes,
+		files:              make(map[uint32]*os.File),
+	}
+	for _, fileId := range fileIds {
+		file, err := os.OpenFile(FilePath(fileId, directory), os.O_RDWR|os.O_APPEND, 0644)
+		if err != nil {
+			_ = valueLog.Close()
+			return nil, err
+		}
+		valueLog.files[fileId] = file
+	}
+	if len(fileIds) > 0 {
+		activeFileId := fileIds[len(fileIds)-1]
+		stat, err := valueLog.files[activeFileId].Stat()
+		if err != nil {
+			_ = valueLog.Close()
+			return nil, err
+		}
+		valueLog.activeFile, valueLog.activeFileId, valueLog.activeFileSize = valueLog.files[activeFileId], activeFileId, uint64(stat.Size())
+	}
+	return valueLog, nil
+}
+
+// FilePath returns the path of the value log file identified by the fileId in the directory.
+func FilePath(fileId uint32, directory string) string {
+	return filepath.Join(directory, fmt.Sprintf("%v%v", fileId, fileExtension))
+}
+
+// Append appends all the values to the ValueLog, and returns their ValuePointers in the order of the values.
+// The values that go to the same file are appended with a single write. The values are not synced (refer to Sync).
+func (valueLog *ValueLog) Append(values [][]byte) ([]ValuePointer, error) {
+	pointers := make([]ValuePointer, 0, len(values))
+	var pending []byte
+	for _, value := range values {
+		entrySize := uint64(checksumSize + len(value))
+		if valueLog.activeFile == nil || valueLog.isFull(uint64(len(pending)), entrySize) {
+			if err := valueLog.write(pending); err != nil {
+				return nil, err
+			}
+			pending = pending[:0]
+			if err := valueLog.rotate(); err != nil {
+				return nil, err
+			}
+		}
+		pointers = append(pointers, ValuePointer{
+			FileId: valueLog.activeFileId,
+			Offset: uint32(valueLog.activeFileSize + uint64(len(pending))),
+			Size:   uint32(len(value)),
+		})
+		pending = binary.LittleEndian.AppendUint32(pending, crc32.Checksum(value, castagnoliTable))
+		pending = append(pending, value...)
+	}
+	if err := valueLog.write(pending); err != nil {
+		return nil, err
+	}
+	return pointers, nil
+}
+
+// Read returns the value the pointer points to.
+// It returns errors.InvalidValuePointerErr if the file of the pointer does not exist, and errors.CorruptValueErr if the
+// checksum of the value does not match.
+func (valueLog *ValueLog) Read(pointer ValuePointer) ([]byte, error) {
+	valueLog.lock.RLock()
+	file, ok := valueLog.files[pointer.FileId]
+	valueLog.lock.RUnlock()
+	if !ok {
+		return nil, errors.InvalidValuePointerErr
+	}
+	entry := make([]byte, checksumSize+int(pointer.Size))
+	if _, err := file.ReadAt(entry, int64(pointer.Offset)); err != nil {
+		return nil, err
+	}
+	value := entry[checksumSize:]
+	if binary.LittleEndian.Uint32(entry) != crc32.Checksum(value, castagnoliTable) {
+		return nil, errors.CorruptValueErr
+	}
+	return value, nil
+}
+
+// Sync commits the values appended to the active file to the disk. The other files are synced when they stop being active.
+func (valueLog *ValueLog) Sync() error {
+	if valueLog.activeFile == nil {
+		return nil
+	}
+	return valueLog.activeFile.Sync()
+}
+
+// Close syncs the active file, and closes all the files. No value can be appended or read after Close.
+func (valueLog *ValueLog) Close() error {
+	err := valueLog.Sync()
+
+	valueLog.lock.Lock()
+	defer valueLog.lock.Unlock()
+
+	for fileId, file := range valueLog.files {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(valueLog.files, fileId)
+	}
+	valueLog.activeFile = nil
+	return err
+}
+
+// isFull returns true if the active file, along with the pendingSize bytes that are yet to be written to it, can not
+// accommodate an entry of entrySize bytes. An empty active file is never full, so an entry larger than maxFileSizeInBytes
+// gets a file of its own.
+func (valueLog *ValueLog) isFull(pendingSize uint64, entrySize uint64) bool {
+	usedSize := valueLog.activeFileSize + pendingSize
+	return usedSize > 0 && usedSize+entrySize > valueLog.maxFileSizeInBytes
+}
+
+// write appends the encoded entries to the active file.
+func (valueLog *ValueLog) write(entries []byte) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	bytesWritten, err := valueLog.activeFile.Write(entries)
+	if err != nil {
+		return err
+	}
+	if bytesWritten < len(entries) {
+		return fmt.Errorf("could not append %v bytes to the value log", len(entries))
+	}
+	valueLog.activeFileSize = valueLog.activeFileSize + uint64(bytesWritten)
+	return nil
+}
+
+// rotate syncs the active file (if any), and creates a new active file with the next fileId.
+func (valueLog *ValueLog) rotate() error {
+	fileId := uint32(0)
+	if valueLog.activeFile != nil {
+		if err := valueLog.activeFile.Sync(); err != nil {
+			return err
+		}
+		fileId = valueLog.activeFileId + 1
+	}
+	file, err := os.OpenFile(FilePath(fileId, valueLog.directory), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	valueLog.lock.Lock()
+	valueLog.files[fileId] = file
+	valueLog.lock.Unlock()
+
+	valueLog.activeFile, valueLog.activeFileId, valueLog.activeFileSize = file, fileId, 0
+	return nil
+}
+
+// existingFileIds returns the file ids of all the value log files in the directory, in the increasing order.
+func existingFileIds(directory string) ([]uint32, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []uint32
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != fileExtension {
+			continue
+		}
+		fileId, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), fileExtension), 10, 32)
+		if err != nil {
+			continue
+		}
+		fileIds = append(fileIds, uint32(fileId))
+	}
+	sort.Slice(fileIds, func(i, j int) bool {
+		return fileIds[i] < fileIds[j]
+	})
+	return fileIds, nil
+}
@@ -0,0 +1,127 @@
+package vlog
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"os"
+	"testing"
+	"tinydb/pkg/kv/vlog/errors"
+)
+
+func TestAppendsAndReadsValues(t *testing.T) {
+	valueLog, err := Open(t.TempDir(), 1024)
+	assert.Nil(t, err)
+	defer valueLog.Close()
+
+	pointers, err := valueLog.Append([][]byte{[]byte("Hard disk"), []byte("Solid state drive")})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pointers))
+
+	value, err := valueLog.Read(pointers[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "Hard disk", string(value))
+
+	value, err = valueLog.Read(pointers[1])
+	assert.Nil(t, err)
+	assert.Equal(t, "Solid state drive", string(value))
+}
+
+func TestDoesNotCreateAFileWithoutValues(t *testing.T) {
+	directory := t.TempDir()
+	valueLog, err := Open(directory, 1024)
+	assert.Nil(t, err)
+	assert.Nil(t, valueLog.Close())
+
+	_, err = os.Stat(FilePath(0, directory))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRotatesTheActiveFileOnceItIsFull(t *testing.T) {
+	valueLog, err := Open(t.TempDir(), 32)
+	assert.Nil(t, err)
+	defer valueLog.Close()
+
+	pointers, err := valueLog.Append([][]byte{[]byte("Hard disk drive"), []byte("Solid state drive")})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), pointers[0].FileId)
+	assert.Equal(t, uint32(1), pointers[1].FileId)
+	assert.Equal(t, uint32(0), pointers[1].Offset)
+
+	value, err := valueLog.Read(pointers[1])
+	assert.Nil(t, err)
+	assert.Equal(t, "Solid state drive", string(value))
+}
+
+func TestReadsTheValuesAfterReopeningTheValueLog(t *testing.T) {
+	directory := t.TempDir()
+	valueLog, err := Open(directory, 1024)
+	assert.Nil(t, err)
+	pointers, err := valueLog.Append([][]byte{[]byte("Hard disk")})
+	assert.Nil(t, err)
+	assert.Nil(t, valueLog.Close())
+
+	valueLog, err = Open(directory, 1024)
+	assert.Nil(t, err)
+	defer valueLog.Close()
+
+	morePointers, err := valueLog.Append([][]byte{[]byte("Solid state drive")})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), morePointers[0].FileId)
+	assert.True(t, morePointers[0].Offset > pointers[0].Offset)
+
+	value, err := valueLog.Read(pointers[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "Hard disk", string(value))
+
+	value, err = valueLog.Read(morePointers[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "Solid state drive", string(value))
+}
+
+func TestReadsACorruptValue(t *testing.T) {
+	directory := t.TempDir()
+	valueLog, err := Open(directory, 1024)
+	assert.Nil(t, err)
+	defer valueLog.Close()
+
+	pointers, err := valueLog.Append([][]byte{[]byte("Hard disk")})
+	assert.Nil(t, err)
+
+	file, err := os.OpenFile(FilePath(0, directory), os.O_RDWR, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteAt([]byte("h"), int64(pointers[0].Offset)+int64(checksumSize))
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	_, err = valueLog.Read(pointers[0])
+	assert.Equal(t, errors.CorruptValueErr, err)
+}
+
+func TestReadsAValuePointerToAMissingFile(t *testing.T) {
+	valueLog, err := Open(t.TempDir(), 1024)
+	assert.Nil(t, err)
+	defer valueLog.Close()
+
+	_, err = valueLog.Read(ValuePointer{FileId: 5, Offset: 0, Size: 4})
+	assert.Equal(t, errors.InvalidValuePointerErr, err)
+}
+
+func TestDoesNotOpenAValueLogWithAFileSizeBeyondTheOffsetOfAValuePointer(t *testing.T) {
+	_, err := Open(t.TempDir(), math.MaxUint32+1)
+	assert.Equal(t, errors.FileSizeTooLargeErr, err)
+
+	valueLog, err := Open(t.TempDir(), math.MaxUint32)
+	assert.Nil(t, err)
+	_ = valueLog.Close()
+}
+
+func TestEncodesAndDecodesAValuePointer(t *testing.T) {
+	pointer := ValuePointer{FileId: 2, Offset: 4096, Size: 100 * 1024}
+
+	decoded, err := DecodeValuePointer(pointer.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, pointer, decoded)
+
+	_, err = DecodeValuePointer([]byte("pointer"))
+	assert.Equal(t, errors.InvalidValuePointerErr, err)
+}
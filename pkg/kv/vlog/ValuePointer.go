@@ -0,0 +1,48 @@
+package vlog
+
+import (
+	"encoding/binary"
+	"tinydb/pkg/kv/vlog/errors"
+	"unsafe"
+)
+
+const uint32Size = int(unsafe.Sizeof(uint32(0)))
+
+// ValuePointerSize is the size of an encoded ValuePointer.
+const ValuePointerSize = 3 * uint32Size
+
+// ValuePointer points to a value in the ValueLog: the id of the value log file, the offset of the entry in the file,
+// and the size of the value.
+/*
+Structure of an encoded ValuePointer.
++----------------+----------------+----------------+
+| 4 bytes fileId | 4 bytes offset | 4 bytes size   |
++----------------+----------------+----------------+
+*/
+type ValuePointer struct {
+	FileId uint32
+	Offset uint32
+	Size   uint32
+}
+
+// Encode encodes the ValuePointer to a byte slice.
+func (pointer ValuePointer) Encode() []byte {
+	encoded := make([]byte, ValuePointerSize)
+	binary.LittleEndian.PutUint32(encoded, pointer.FileId)
+	binary.LittleEndian.PutUint32(encoded[uint32Size:], pointer.Offset)
+	binary.LittleEndian.PutUint32(encoded[2*uint32Size:], pointer.Size)
+	return encoded
+}
+
+// DecodeValuePointer decodes the ValuePointer from the byte slice.
+// It returns errors.InvalidValuePointerErr if the byte slice is not an encoded ValuePointer.
+func DecodeValuePointer(encoded []byte) (ValuePointer, error) {
+	if len(encoded) != ValuePointerSize {
+		return ValuePointer{}, errors.InvalidValuePointerErr
+	}
+	return ValuePointer{
+		FileId: binary.LittleEndian.Uint32(encoded),
+		Offset: binary.LittleEndian.Uint32(encoded[uint32Size:]),
+		Size:   binary.LittleEndian.Uint32(encoded[2*uint32Size:]),
+	}, nil
+}
@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var CorruptValueErr = errors.New("value log entry is corrupt, its checksum does not match")
+var InvalidValuePointerErr = errors.New("value pointer is invalid, it does not point to a value in the value log")
+var FileSizeTooLargeErr = errors.New("value log file size is too large, it must not exceed 4GiB (the largest offset of a value pointer)")
@@ -0,0 +1,202 @@
+package tinydb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+	"tinydb/pkg/kv"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/txn"
+	txnErrors "tinydb/pkg/kv/txn/errors"
+	dbErrors "tinydb/pkg/tinydb/errors"
+)
+
+// DB is the entry point for the clients of tinydb.
+// DB wires the kv.Workspace, the txn.TransactionExecutor and the txn.Oracle together, and provides managed transactions:
+// Update runs the logic in a txn.ReadWriteTransaction and View runs the logic in a txn.ReadonlyTransaction.
+// The managed transactions take care of discarding the transactions, retrying the conflicts and waiting for the commits to be applied.
+// The lock protects the closed flag: a transaction is started only if the DB is not closed, and Close waits for all the
+// running transactions (tracked in `running`) to finish.
+// The DB becomes read-only (degraded) if the txn.TransactionExecutor fails to apply a commit (for example, a failed write
+// to the WAL): View keeps working, and Update returns the error (refer to IsReadOnly).
+type DB struct {
+	workspace     *kv.Workspace
+	executor      *txn.TransactionExecutor
+	oracle        *txn.Oracle
+	directoryLock *DirectoryLock
+	lock          sync.Mutex
+	closed        bool
+	running       sync.WaitGroup
+}
+
+// Open opens the DB in the DbDirectory of the options, creating the DbDirectory if it does not exist.
+// Open acquires the DirectoryLock on the DbDirectory, so opening the same DbDirectory again (before Close) fails with
+// errors.DbDirectoryLockedErr. The state left behind by the previous run is recovered by kv.Open.
+func Open(options *option.Options) (*DB, error) {
+	if err := os.MkdirAll(options.DbDirectory, 0755); err != nil {
+		return nil, err
+	}
+	directoryLock, err := lockDirectory(options.DbDirectory)
+	if err != nil {
+		return nil, err
+	}
+	workspace, err := kv.Open(options)
+	if err != nil {
+		_ = directoryLock.release()
+		return nil, err
+	}
+	db := NewDB(workspace)
+	db.directoryLock = directoryLock
+	return db, nil
+}
+
+// NewDB creates a new instance of DB over the kv.Workspace. Unlike Open, it does not lock the DbDirectory.
+func NewDB(workspace *kv.Workspace) *DB {
+	executor := txn.NewTransactionExecutor(workspace)
+	return &DB{
+		workspace: workspace,
+		executor:  executor,
+		oracle:    txn.NewOracle(executor),
+	}
+}
+
+// Update runs the logic in a new txn.ReadWriteTransaction and commits the transaction if the logic does not return an error.
+// If the commit fails with errors.ConflictErr, Update runs the logic again in a new transaction, waiting for the
+// ConflictRetryBackoff (doubled after every retry) before each retry, upto MaxConflictRetries times.
+// Update returns after the committed transaction is applied to the kv.Workspace.
+// The logic may run more than once, so it should not have side effects outside the transaction.
+// A transaction in which the logic does not write (or delete) any key is not committed.
+// Update returns errors.DbClosedErr if the DB is closed, and the error returned by Err (without running the logic) if the DB is read-only.
+// If the committed transaction can not be applied, Update returns the error and the DB becomes read-only.
+func (db *DB) Update(logic func(transaction *txn.ReadWriteTransaction) error) error {
+	return db.UpdateWithContext(context.Background(), logic)
+}
+
+// UpdateWithContext runs the logic like Update, but it returns ctx.Err() if the ctx is done while beginning the transaction,
+// committing the transaction (refer to txn.ReadWriteTransaction.CommitWithContext), waiting for the committed
+// transaction to be applied, or waiting before a retry.
+// If the ctx is done while waiting for the committed transaction to be applied, the transaction may still be applied.
+func (db *DB) UpdateWithContext(ctx context.Context, logic func(transaction *txn.ReadWriteTransaction) error) error {
+	if err := db.beginRunning(); err != nil {
+		return err
+	}
+	defer db.running.Done()
+	if err := db.Err(); err != nil {
+		return err
+	}
+
+	options := db.workspace.Options()
+	backoff := options.ConflictRetryBackoff
+	for retry := 0; ; retry++ {
+		err := db.update(ctx, logic)
+		if !errors.Is(err, txnErrors.ConflictErr) || retry >= options.MaxConflictRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = backoff * 2
+	}
+}
+
+// View runs the logic in a new txn.ReadonlyTransaction, and discards the transaction once the logic returns.
+// View returns errors.DbClosedErr if the DB is closed.
+func (db *DB) View(logic func(transaction *txn.ReadonlyTransaction) error) error {
+	return db.ViewWithContext(context.Background(), logic)
+}
+
+// ViewWithContext runs the logic like View, but it returns ctx.Err() (without running the logic) if the ctx is done
+// before the transaction begins.
+func (db *DB) ViewWithContext(ctx context.Context, logic func(transaction *txn.ReadonlyTransaction) error) error {
+	if err := db.beginRunning(); err != nil {
+		return err
+	}
+	defer db.running.Done()
+
+	transaction, err := txn.NewReadonlyTransactionWithContext(ctx, db.oracle)
+	if err != nil {
+		return err
+	}
+	defer transaction.Discard()
+
+	return logic(transaction)
+}
+
+// IsReadOnly returns true if the DB is in the read-only (degraded) state, which it enters after a failure to apply a
+// committed transaction. The DB does not leave the read-only state, it needs to be closed and opened again.
+func (db *DB) IsReadOnly() bool {
+	return db.Err() != nil
+}
+
+// Err returns the error that put the DB in the read-only state (wrapping txn errors.WritesStoppedErr), nil if the DB accepts writes.
+func (db *DB) Err() error {
+	return db.executor.Err()
+}
+
+// Close closes the DB. Close involves the following:
+// 1. Rejecting the new transactions with errors.DbClosedErr, and waiting for the running transactions to finish.
+// 2. Stopping the txn.Oracle, which drains the txn.TransactionExecutor (all the submitted commits are applied).
+// 3. Closing the kv.Workspace, which syncs and closes the WAL of the active memtable (the memtables are recovered by the next Open).
+// 4. Releasing the DirectoryLock.
+// Close returns errors.DbClosedErr if the DB is already closed.
+func (db *DB) Close() error {
+	db.lock.Lock()
+	if db.closed {
+		db.lock.Unlock()
+		return dbErrors.DbClosedErr
+	}
+	db.closed = true
+	db.lock.Unlock()
+
+	db.running.Wait()
+	db.oracle.Stop()
+	err := db.workspace.Close()
+	if db.directoryLock != nil {
+		if lockErr := db.directoryLock.release(); err == nil {
+			err = lockErr
+		}
+	}
+	return err
+}
+
+// beginRunning tracks a new running transaction, if the DB is not closed.
+func (db *DB) beginRunning() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return dbErrors.DbClosedErr
+	}
+	db.running.Add(1)
+	return nil
+}
+
+// update runs the logic in a new txn.ReadWriteTransaction, commits the transaction and waits till it is applied.
+func (db *DB) update(ctx context.Context, logic func(transaction *txn.ReadWriteTransaction) error) error {
+	transaction, err := txn.NewReadWriteTransactionWithContext(ctx, db.oracle)
+	if err != nil {
+		return err
+	}
+	defer transaction.Discard()
+
+	if err := logic(transaction); err != nil {
+		return err
+	}
+	done, err := transaction.CommitWithContext(ctx)
+	if errors.Is(err, txnErrors.EmptyTransactionErr) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
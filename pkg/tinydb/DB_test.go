@@ -0,0 +1,226 @@
+package tinydb
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"tinydb/pkg/kv/option"
+	"tinydb/pkg/kv/txn"
+	txnErrors "tinydb/pkg/kv/txn/errors"
+	dbErrors "tinydb/pkg/tinydb/errors"
+)
+
+func newTestDB(t *testing.T, options *option.Options) *DB {
+	db, err := Open(options.SetDbDirectory(t.TempDir()))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestUpdateAndView(t *testing.T) {
+	db := newTestDB(t, option.DefaultOptions())
+
+	err := db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		return transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	})
+	assert.Nil(t, err)
+
+	err = db.View(func(transaction *txn.ReadonlyTransaction) error {
+		value, ok, _ := transaction.Get([]byte("HDD"))
+		assert.Equal(t, true, ok)
+		assert.Equal(t, []byte("Hard disk"), value.ValueSlice())
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestUpdateDoesNotCommitGivenTheLogicReturnsAnError(t *testing.T) {
+	db := newTestDB(t, option.DefaultOptions())
+
+	logicErr := errors.New("logic failed")
+	err := db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		_ = transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+		return logicErr
+	})
+	assert.Equal(t, logicErr, err)
+
+	_ = db.View(func(transaction *txn.ReadonlyTransaction) error {
+		_, ok, _ := transaction.Get([]byte("HDD"))
+		assert.Equal(t, false, ok)
+		return nil
+	})
+}
+
+func TestUpdateWithoutWrites(t *testing.T) {
+	db := newTestDB(t, option.DefaultOptions())
+
+	err := db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		transaction.Get([]byte("HDD"))
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	db := newTestDB(t, option.DefaultOptions())
+
+	attempts := 0
+	err := db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		attempts++
+		transaction.Get([]byte("counter"))
+		if attempts == 1 {
+			concurrentErr := db.Update(func(concurrentTransaction *txn.ReadWriteTransaction) error {
+				return concurrentTransaction.PutOrUpdate([]byte("counter"), []byte("1"))
+			})
+			assert.Nil(t, concurrentErr)
+		}
+		return transaction.PutOrUpdate([]byte("counter"), []byte("2"))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	_ = db.View(func(transaction *txn.ReadonlyTransaction) error {
+		value, _, _ := transaction.Get([]byte("counter"))
+		assert.Equal(t, []byte("2"), value.ValueSlice())
+		return nil
+	})
+}
+
+func TestUpdateGivesUpAfterTheMaximumRetries(t *testing.T) {
+	db := newTestDB(t, option.DefaultOptions().SetMaxConflictRetries(1))
+
+	attempts := 0
+	err := db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		attempts++
+		transaction.Get([]byte("counter"))
+		concurrentErr := db.Update(func(concurrentTransaction *txn.ReadWriteTransaction) error {
+			return concurrentTransaction.PutOrUpdate([]byte("counter"), []byte("1"))
+		})
+		assert.Nil(t, concurrentErr)
+		return transaction.PutOrUpdate([]byte("counter"), []byte("2"))
+	})
+	assert.Equal(t, txnErrors.ConflictErr, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestReopensTheDBAfterClose(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+
+	db, err := Open(options)
+	assert.Nil(t, err)
+	err = db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		return transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db, err = Open(options)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_ = db.View(func(transaction *txn.ReadonlyTransaction) error {
+		value, ok, _ := transaction.Get([]byte("HDD"))
+		assert.Equal(t, true, ok)
+		assert.Equal(t, []byte("Hard disk"), value.ValueSlice())
+		return nil
+	})
+}
+
+func TestOpensTheSameDirectoryTwice(t *testing.T) {
+	options := option.DefaultOptions().SetDbDirectory(t.TempDir())
+
+	db, err := Open(options)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = Open(options)
+	assert.Equal(t, dbErrors.DbDirectoryLockedErr, err)
+}
+
+func TestRejectsTransactionsAfterClose(t *testing.T) {
+	db, err := Open(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	err = db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		return transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	})
+	assert.Equal(t, dbErrors.DbClosedErr, err)
+
+	err = db.View(func(transaction *txn.ReadonlyTransaction) error {
+		return nil
+	})
+	assert.Equal(t, dbErrors.DbClosedErr, err)
+	assert.Equal(t, dbErrors.DbClosedErr, db.Close())
+}
+
+func TestEntersTheReadOnlyStateAfterAFailureToApplyACommit(t *testing.T) {
+	db, err := Open(option.DefaultOptions().SetDbDirectory(t.TempDir()))
+	assert.Nil(t, err)
+	defer func() {
+		db.oracle.Stop()
+		_ = db.directoryLock.release()
+	}()
+
+	err = db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		return transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, false, db.IsReadOnly())
+
+	// Closing the kv.Workspace closes the WAL of the active memtable, so the next commit fails to write to the WAL.
+	_ = db.workspace.Close()
+
+	err = db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		return transaction.PutOrUpdate([]byte("SSD"), []byte("Solid state drive"))
+	})
+	assert.True(t, errors.Is(err, txnErrors.WritesStoppedErr))
+	assert.Equal(t, true, db.IsReadOnly())
+	assert.Equal(t, err, db.Err())
+
+	ran := false
+	err = db.Update(func(transaction *txn.ReadWriteTransaction) error {
+		ran = true
+		return nil
+	})
+	assert.True(t, errors.Is(err, txnErrors.WritesStoppedErr))
+	assert.Equal(t, false, ran)
+
+	err = db.View(func(transaction *txn.ReadonlyTransaction) error {
+		_, ok, _ := transaction.Get([]byte("SSD"))
+		assert.Equal(t, false, ok)
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestUpdateAndViewWithAContextThatIsDone(t *testing.T) {
+	db := newTestDB(t, option.DefaultOptions())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ran := false
+	err := db.UpdateWithContext(ctx, func(transaction *txn.ReadWriteTransaction) error {
+		ran = true
+		return transaction.PutOrUpdate([]byte("HDD"), []byte("Hard disk"))
+	})
+	assert.Equal(t, context.Canceled, err)
+
+	err = db.ViewWithContext(ctx, func(transaction *txn.ReadonlyTransaction) error {
+		ran = true
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, false, ran)
+
+	err = db.ViewWithContext(context.Background(), func(transaction *txn.ReadonlyTransaction) error {
+		_, ok, _ := transaction.Get([]byte("HDD"))
+		assert.Equal(t, false, ok)
+		return nil
+	})
+	assert.Nil(t, err)
+}
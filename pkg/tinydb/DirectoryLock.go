@@ -0,0 +1,40 @@
+package tinydb
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const lockFileName = "LOCK"
+
+// DirectoryLock is an exclusive lock on the DbDirectory, which ensures that only one DB opens the DbDirectory at a time.
+// It is a lock on the LOCK file inside the DbDirectory (flock on unix, LockFileEx on windows, refer to lockFile). The
+// operating system releases the lock if the process dies, so a stale LOCK file does not prevent the DbDirectory from
+// being opened again.
+type DirectoryLock struct {
+	file *os.File
+}
+
+// lockDirectory acquires the DirectoryLock on the directory.
+// It returns errors.DbDirectoryLockedErr if the directory is already locked (by this or another process).
+// It returns errors.DirectoryLockUnsupportedErr on a platform that is neither unix nor windows.
+func lockDirectory(directory string) (*DirectoryLock, error) {
+	file, err := os.OpenFile(filepath.Join(directory, lockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &DirectoryLock{file: file}, nil
+}
+
+// release releases the DirectoryLock. The LOCK file is left in the directory.
+func (lock *DirectoryLock) release() error {
+	if err := unlockFile(lock.file); err != nil {
+		_ = lock.file.Close()
+		return err
+	}
+	return lock.file.Close()
+}
@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package tinydb
+
+import (
+	"os"
+	"tinydb/pkg/tinydb/errors"
+)
+
+// lockFile returns errors.DirectoryLockUnsupportedErr, the DbDirectory can not be locked on this platform.
+func lockFile(_ *os.File) error {
+	return errors.DirectoryLockUnsupportedErr
+}
+
+// unlockFile does nothing, lockFile never acquires a lock on this platform.
+func unlockFile(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package tinydb
+
+import (
+	"os"
+	"syscall"
+	"tinydb/pkg/tinydb/errors"
+)
+
+// lockFile acquires an exclusive advisory lock (flock) on the file, without waiting for the lock.
+// It returns errors.DbDirectoryLockedErr if the file is already locked.
+func lockFile(file *os.File) error {
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return errors.DbDirectoryLockedErr
+		}
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock acquired by lockFile.
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package tinydb
+
+import (
+	"os"
+	"syscall"
+	"tinydb/pkg/tinydb/errors"
+	"unsafe"
+)
+
+const (
+	lockFileFailImmediately = 0x00000001
+	lockFileExclusiveLock   = 0x00000002
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockFile acquires an exclusive lock (LockFileEx) on the first byte of the file, without waiting for the lock.
+// It returns errors.DbDirectoryLockedErr if the file is already locked.
+func lockFile(file *os.File) error {
+	overlapped := new(syscall.Overlapped)
+	result, _, err := procLockFileEx.Call(
+		file.Fd(),
+		uintptr(lockFileExclusiveLock|lockFileFailImmediately),
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(overlapped)),
+	)
+	if result == 0 {
+		if err == errorLockViolation {
+			return errors.DbDirectoryLockedErr
+		}
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock acquired by lockFile.
+func unlockFile(file *os.File) error {
+	overlapped := new(syscall.Overlapped)
+	result, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(overlapped)))
+	if result == 0 {
+		return err
+	}
+	return nil
+}
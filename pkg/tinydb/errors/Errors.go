@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var DbClosedErr = errors.New("db is closed")
+var DbDirectoryLockedErr = errors.New("db directory is already opened by another DB")
+var DirectoryLockUnsupportedErr = errors.New("db directory can not be locked on this platform")